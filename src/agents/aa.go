@@ -3,16 +3,21 @@ package agentspkg
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
-	"net/http"
-	"os"
 	"strings"
+	"sync/atomic"
 
+	budgetpkg "main/src/budget"
 	buspkg "main/src/bus"
+	cachepkg "main/src/cache"
 	commandpkg "main/src/command"
 	eventpkg "main/src/event"
+	indexpkg "main/src/index"
 	modelpkg "main/src/model"
+	settingspkg "main/src/settings"
+	toolspkg "main/src/tools"
 )
 
 type Command = commandpkg.Command
@@ -24,145 +29,328 @@ type OptimizedBus = buspkg.OptimizedBus
 type Event = eventpkg.Event
 
 type AAgent struct {
-	Logger  *slog.Logger
-	Bus     *OptimizedBus
-	Command *Command
-}
+	Logger       *slog.Logger
+	Bus          *OptimizedBus
+	Command      *Command
+	Db           *Database
+	AgentName    string // vacío = "aa"
+	Provider     Provider
+	Model        string
+	Instructions string
+	Temperature  *float64
+	History      bool                  // envía siempre el historial local en vez del estado remoto
+	Retry        RetryPolicy           // vacío = DefaultRetryPolicy
+	Tools        []Tool                // herramientas que se anuncian al modelo
+	MaxRounds    int                   // vueltas de herramientas por respuesta; 0 = 8
+	Budget       *budgetpkg.Budget     // nil = sin límites de gasto
+	CompactAt    int                   // tokens estimados que disparan el resumen; 0 = nunca
+	CompactKeep  int                   // mensajes recientes que no se resumen; 0 = 6
+	Settings     *settingspkg.Settings // `/model` y `/set`: sustituyen modelo y parámetros
+	Index        *indexpkg.Index       // nil = sin recuperación de documentos
+	Cache        *cachepkg.Cache       // nil = sin caché de respuestas
+	ProviderName string                // proveedor de config; distingue endpoints en la caché
 
-type Response struct {
-	ID           string `json:"id"`
-	Object       string `json:"object"`
-	CreatedAt    int    `json:"created_at"`
-	Status       string `json:"status"`
-	Background   bool   `json:"background"`
-	Conversation struct {
-		ID string `json:"id"`
-	} `json:"conversation"`
-	Error             any    `json:"error"`
-	IncompleteDetails any    `json:"incomplete_details"`
-	Instructions      any    `json:"instructions"`
-	MaxOutputTokens   any    `json:"max_output_tokens"`
-	MaxToolCalls      any    `json:"max_tool_calls"`
-	Model             string `json:"model"`
-	Output            []struct {
-		ID      string `json:"id"`
-		Type    string `json:"type"`
-		Summary []any  `json:"summary,omitempty"`
-		Status  string `json:"status,omitempty"`
-		Content []struct {
-			Type        string `json:"type"`
-			Annotations []any  `json:"annotations"`
-			Logprobs    []any  `json:"logprobs"`
-			Text        string `json:"text"`
-		} `json:"content,omitempty"`
-		Role string `json:"role,omitempty"`
-	} `json:"output"`
-	ParallelToolCalls  bool `json:"parallel_tool_calls"`
-	PreviousResponseID any  `json:"previous_response_id"`
-	PromptCacheKey     any  `json:"prompt_cache_key"`
-	Reasoning          struct {
-		Effort  string `json:"effort"`
-		Summary any    `json:"summary"`
-	} `json:"reasoning"`
-	SafetyIdentifier any     `json:"safety_identifier"`
-	ServiceTier      string  `json:"service_tier"`
-	Store            bool    `json:"store"`
-	Temperature      float64 `json:"temperature"`
-	Text             struct {
-		Format struct {
-			Type string `json:"type"`
-		} `json:"format"`
-		Verbosity string `json:"verbosity"`
-	} `json:"text"`
-	ToolChoice  string  `json:"tool_choice"`
-	Tools       []any   `json:"tools"`
-	TopLogprobs int     `json:"top_logprobs"`
-	TopP        float64 `json:"top_p"`
-	Truncation  string  `json:"truncation"`
-	Usage       struct {
-		InputTokens        int `json:"input_tokens"`
-		InputTokensDetails struct {
-			CachedTokens int `json:"cached_tokens"`
-		} `json:"input_tokens_details"`
-		OutputTokens        int `json:"output_tokens"`
-		OutputTokensDetails struct {
-			ReasoningTokens int `json:"reasoning_tokens"`
-		} `json:"output_tokens_details"`
-		TotalTokens int `json:"total_tokens"`
-	} `json:"usage"`
-	User     any `json:"user"`
-	Metadata struct {
-	} `json:"metadata"`
+	inflight Inflight
+	draining atomic.Bool // Drain en marcha: no se aceptan mensajes nuevos
 }
 
-type Payload struct {
-	Model        string `json:"model"`
-	Conversation string `json:"conversation"`
-	Input        string `json:"input"`
+func (a *AAgent) Name() string {
+	if a.AgentName != "" {
+		return a.AgentName
+	}
+	return "aa"
 }
 
-func (p *Payload) GetBody() string {
-	body, err := json.Marshal(Payload{
-		Model:        p.Model,
-		Conversation: p.Conversation,
-		Input:        p.Input,
+// Request llama al proveedor reintentando los fallos temporales
+func (a *AAgent) Request(ctx context.Context, req ProviderRequest, onDelta func(text string)) (*ProviderResponse, error) {
+	// Petición idéntica reciente: se responde desde la caché //
+	key := a.cacheKey(req)
+	if response, ok := a.cached(key); ok {
+		a.Logger.Info("Response served from cache", "agent", a.Name(), "model", req.Model)
+		return response, nil
+	}
+
+	a.Bus.Publish(eventpkg.EvtSystem, "loading")
+	defer a.Bus.Publish(eventpkg.EvtSystem, "loaded")
+
+	policy := a.Retry
+	if policy.Attempts == 0 {
+		policy = DefaultRetryPolicy
+	}
+
+	var response *ProviderResponse
+	err := Retry(ctx, policy, func(attempt int) error {
+		var err error
+		response, err = a.Provider.Complete(ctx, req, onDelta)
+		if err != nil {
+			a.Logger.Error("Failed to request",
+				"provider", a.Provider.Name(),
+				"attempt", attempt,
+				"transient", IsTransient(err),
+				"error", err,
+			)
+		}
+		return err
 	})
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	return string(body)
+
+	a.store(key, response)
+	return response, nil
 }
 
-func (a *AAgent) Name() string { return "aa" }
+// Reply responde a un mensaje humano en el hilo del que viene
+func (a *AAgent) Reply(ctx context.Context, msg MessageModel) {
+	a.Logger.Info("Received text message")
+	request := ProviderRequest{
+		Model:        a.Model,
+		Instructions: a.ThreadInstructions(msg.ThreadId),
+		Temperature:  a.Temperature,
+		Stream:       true,
+	}
+	a.applySettings(&request, msg.ThreadId)
+	request.Conversation, request.Input = ThreadContext(a.Db, a.Name(), a.History, msg)
+	// el id remoto cambia en cada turno: la caché se indexa con el historial local //
+	if a.Cache != nil && request.Conversation != "" {
+		_, local := ThreadContext(a.Db, a.Name(), true, msg)
+		request.History = local[:len(local)-1]
+	}
+	for _, tool := range a.Tools {
+		request.Tools = append(request.Tools, tool.Spec())
+	}
+	maxRounds := a.MaxRounds
+	if maxRounds <= 0 {
+		maxRounds = 8
+	}
 
-func (a *AAgent) Request(body string) *Response {
-	oai_url := os.Getenv("OPENAI_API_URL")
-	oai_key := os.Getenv("OPENAI_API_KEY")
+	message := MessageModel{
+		Id:        toolspkg.GenerateUUID(),
+		ThreadId:  msg.ThreadId,
+		ReplyTo:   msg.Id,
+		Type:      modelpkg.TyText,
+		Source:    modelpkg.ScAssistant,
+		WrittenBy: a.Name(),
+	}
 
-	client := &http.Client{}
+	reqCtx, done := a.inflight.Begin(ctx)
+	defer done()
 
-	req, err := http.NewRequest(
-		"POST",
-		fmt.Sprintf("%s/responses", oai_url),
-		strings.NewReader(body),
-	)
-	if err != nil {
-		panic(err)
+	// El hilo creció demasiado: se resume lo antiguo antes de responder //
+	if input, ok := a.Compact(reqCtx, msg); ok {
+		request.Conversation = ""
+		request.History = nil
+		request.Input = input
 	}
 
-	req.Header.Add("Content-Type", "application/json")
-	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", oai_key))
+	// Fragmentos del índice local relevantes para la pregunta //
+	request.Input = a.Retrieve(msg, request.Input)
 
-	a.Bus.Publish(eventpkg.EvtSystem, "loading")
+	var response *ProviderResponse
+	var err error
+	usage := Usage{Model: request.Model}
+	cached := true
+	hit := false // alguna vuelta salió de la caché
+	for round := 0; ; round++ {
+		// Con el presupuesto agotado no se llama al proveedor //
+		if a.Budget != nil {
+			if err = a.Budget.Check(msg.ThreadId); err != nil {
+				break
+			}
+		}
 
-	res, err := client.Do(req)
+		// Cada delta reemplaza el mensaje parcial en la vista //
+		response, err = a.Request(reqCtx, request, func(text string) {
+			partial := message
+			partial.Text = text
+			a.Bus.Publish(eventpkg.EvtPartial, partial)
+		})
+		// lo servido desde la caché no consume tokens //
+		if err == nil && !response.Cached {
+			usage.Add(response.Usage)
+			cached = false
+		}
+		hit = hit || (err == nil && response.Cached)
+		if err != nil || len(response.ToolCalls) == 0 {
+			break
+		}
+		if round >= maxRounds {
+			response.Text += "\n\n_Límite de llamadas a herramientas alcanzado_"
+			break
+		}
+
+		results := a.RunTools(reqCtx, msg, response.ToolCalls)
+		// Con estado remoto basta enviar los resultados; si no, se reenvía todo //
+		turn := ChatMessage{Role: "assistant", Content: response.Text, ToolCalls: response.ToolCalls}
+		if response.Conversation != "" && !a.History {
+			request.History = append(append(request.History, request.Input...), turn)
+			request.Conversation = response.Conversation
+			request.Input = results
+		} else {
+			// una vuelta servida desde la caché no deja estado remoto: se vuelve al historial local //
+			if request.Conversation != "" {
+				request.Input = append(request.History, request.Input...)
+				request.Conversation = ""
+				request.History = nil
+			}
+			request.Input = append(append(request.Input, turn), results...)
+		}
+	}
 	if err != nil {
-		panic(err)
+		// lo consumido en las vueltas anteriores se guarda con el aviso //
+		if usage.Total() > 0 {
+			message.Usage = &usage
+		} else if ctx.Err() != nil {
+			return
+		}
+		message.Type = modelpkg.TySystem
+		message.Source = modelpkg.ScSystem
+		// Cancelada por la persona (Ctrl+X o /cancel) o al parar el agente //
+		if reqCtx.Err() != nil {
+			message.Text = "Petición a " + a.Name() + " cancelada"
+			a.Bus.Publish(eventpkg.EvtMessage, message)
+			return
+		}
+		// El error reemplaza al parcial (mismo id) y el agente sigue escuchando //
+		message.Text = FailureText(a.Name(), err)
+		var exceeded *budgetpkg.ExceededError
+		if errors.As(err, &exceeded) {
+			message.Text = fmt.Sprintf("**%s no responde**: %s. Ajusta el límite con `/budget`", a.Name(), exceeded.Error())
+		}
+		a.Bus.Publish(eventpkg.EvtMessage, message)
+		return
 	}
 
-	defer res.Body.Close()
+	if msg.ThreadId != "" && !a.History {
+		switch {
+		case hit:
+			// el estado remoto no incluye lo servido desde la caché: el
+			// siguiente turno se envía con el historial local //
+			a.Db.SetConversation(msg.ThreadId, a.Name(), "")
+		case response.Conversation != "":
+			a.Db.SetConversation(msg.ThreadId, a.Name(), response.Conversation)
+		}
+	}
 
-	response := &Response{}
-	derr := json.NewDecoder(res.Body).Decode(response)
-	if derr != nil {
-		panic(derr)
+	message.Text = response.Text
+	message.Cached = cached
+	if usage.Total() > 0 {
+		message.Usage = &usage
 	}
+	a.Bus.Publish(eventpkg.EvtMessage, message)
+}
 
-	if res.StatusCode != http.StatusOK {
-		a.Logger.Error("Failed to request", "status", res.Status)
-		// panic(res.Status)
-		return nil
+// ThreadInstructions combina las instrucciones del agente con las del hilo (`/system` o persona)
+func (a *AAgent) ThreadInstructions(threadId string) string {
+	if a.Db == nil || threadId == "" {
+		return a.Instructions
+	}
+	thread, err := a.Db.GetThread(threadId)
+	if err != nil || thread.Instructions == "" {
+		return a.Instructions
+	}
+	if a.Instructions == "" {
+		return thread.Instructions
 	}
+	return a.Instructions + "\n\n" + thread.Instructions
+}
 
-	a.Bus.Publish(eventpkg.EvtSystem, "loading")
+// applySettings aplica los ajustes globales y del hilo sobre los del agente
+func (a *AAgent) applySettings(request *ProviderRequest, threadId string) {
+	if a.Settings == nil {
+		return
+	}
+	settings := a.Settings.Resolve(threadId)
+	if settings.Model != "" {
+		request.Model = settings.Model
+	}
+	if settings.Temperature != nil {
+		request.Temperature = settings.Temperature
+	}
+	request.TopP = settings.TopP
+	request.MaxOutput = settings.MaxOutputTokens
+	request.Reasoning = settings.ReasoningEffort
+}
+
+// RunTools ejecuta las llamadas pedidas por el modelo (pidiendo aprobación a
+// las peligrosas), publica cada una en el hilo y devuelve sus resultados
+func (a *AAgent) RunTools(ctx context.Context, msg MessageModel, calls []ToolCall) []ChatMessage {
+	results := make([]ChatMessage, 0, len(calls))
+	for _, call := range calls {
+		output, err := a.runTool(ctx, msg, call)
+		if err != nil {
+			output = "error: " + err.Error()
+		}
+		results = append(results, ChatMessage{Role: "tool", Content: output, ToolCallId: call.Id})
+
+		a.Bus.Publish(eventpkg.EvtMessage, MessageModel{
+			Id:        toolspkg.GenerateUUID(),
+			ThreadId:  msg.ThreadId,
+			ReplyTo:   msg.Id,
+			Type:      modelpkg.TyTool,
+			Source:    modelpkg.ScAssistant,
+			WrittenBy: a.Name(),
+			Text:      ToolText(call, output),
+		})
+	}
+	return results
+}
+
+func (a *AAgent) runTool(ctx context.Context, msg MessageModel, call ToolCall) (string, error) {
+	var tool *Tool
+	for idx := range a.Tools {
+		if a.Tools[idx].Name == call.Name {
+			tool = &a.Tools[idx]
+		}
+	}
+	if tool == nil {
+		return "", fmt.Errorf("tool %q not available", call.Name)
+	}
+
+	args := json.RawMessage(call.Arguments)
+	if strings.TrimSpace(call.Arguments) == "" {
+		args = json.RawMessage("{}")
+	}
 
-	return response
+	if tool.Dangerous {
+		approved := RequestApproval(ctx, a.Bus, ApprovalModel{
+			Agent:     a.Name(),
+			Tool:      call.Name,
+			Arguments: call.Arguments,
+			ThreadId:  msg.ThreadId,
+		})
+		if !approved {
+			return "", fmt.Errorf("denied by user")
+		}
+	}
+
+	a.Logger.Info("Running tool", "agent", a.Name(), "tool", call.Name)
+	return tool.Run(ctx, args)
+}
+
+// ToolText muestra la llamada y un extracto del resultado como markdown
+func ToolText(call ToolCall, output string) string {
+	const limit = 1500
+	if len(output) > limit {
+		output = output[:limit] + "\n…"
+	}
+	return fmt.Sprintf("`%s(%s)`\n\n```\n%s\n```", call.Name, call.Arguments, strings.TrimRight(output, "\n"))
+}
+
+// Drain deja de aceptar mensajes y espera a que termine la respuesta en
+// curso; si vence ctx la cancela y queda en el hilo como cancelada
+func (a *AAgent) Drain(ctx context.Context) error {
+	a.draining.Store(true)
+	if err := a.inflight.Wait(ctx); err != nil {
+		a.inflight.Cancel()
+		return err
+	}
+	return nil
 }
 
 func (a *AAgent) Start(ctx context.Context) error {
-	payload := Payload{
-		Model:        os.Getenv("MODEL"),
-		Conversation: os.Getenv("CONVERSATION"),
+	if a.Provider == nil {
+		return fmt.Errorf("agent %s has no provider", a.Name())
 	}
 
 	ch, unsub, err := a.Bus.Subscribe(eventpkg.EvtMessage, 64)
@@ -171,6 +359,16 @@ func (a *AAgent) Start(ctx context.Context) error {
 	}
 	defer unsub()
 
+	a.draining.Store(false)
+
+	// el oyente de cancelaciones termina con esta ejecución (también tras un panic) //
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	if err := ListenCancel(ctx, a.Bus, a.Name(), &a.inflight); err != nil {
+		return err
+	}
+
 	for {
 		select {
 		case <-ctx.Done():
@@ -185,31 +383,25 @@ func (a *AAgent) Start(ctx context.Context) error {
 			case modelpkg.TySystem:
 				//
 			case modelpkg.TyText:
-				if ok, _ := a.Command.IsCommand(msg.Text); !ok {
-
-					a.Logger.Info("Received text message")
-					payload.Input = msg.Text
-					response := a.Request(payload.GetBody())
-
-					/**
-					 * TODO: add error control
-					 */
-					if response == nil {
-						return nil
-					}
-
-					message := MessageModel{
-						/**
-						 * TODO: add thread_id
-						 */
-						ThreadId:  "",
-						Type:      modelpkg.TyText,
-						Source:    modelpkg.ScAssistant,
-						WrittenBy: a.Name(),
-						Text:      response.Output[len(response.Output)-1].Content[0].Text,
-					}
-					a.Bus.Publish(eventpkg.EvtMessage, message)
+				// solo responde a personas que se dirigen a él //
+				if msg.Source != modelpkg.ScHuman || !msg.IsAddressedTo(a.Name()) {
+					break
+				}
+				if ok, _ := a.Command.IsCommand(msg.Text); ok {
+					break
+				}
+				if a.draining.Load() {
+					a.Bus.Publish(eventpkg.EvtMessage, MessageModel{
+						Id:       toolspkg.GenerateUUID(),
+						ThreadId: msg.ThreadId,
+						ReplyTo:  msg.Id,
+						Type:     modelpkg.TySystem,
+						Source:   modelpkg.ScSystem,
+						Text:     a.Name() + " se está deteniendo; vuelve a enviar el mensaje cuando arranque",
+					})
+					break
 				}
+				a.Reply(ctx, msg)
 			case modelpkg.TyCommand:
 				//
 			}
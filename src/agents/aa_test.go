@@ -0,0 +1,72 @@
+package agentspkg
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	eventpkg "main/src/event"
+	modelpkg "main/src/model"
+)
+
+func TestReplyCancelKeepsToolRoundUsage(t *testing.T) {
+	stub := NewStubServer(
+		StubReply{ToolCalls: []ToolCall{{Name: "missing", Arguments: "{}"}}, Usage: Usage{InputTokens: 10, OutputTokens: 5}},
+		StubReply{Text: "nunca llega", Delay: 5 * time.Second},
+	)
+	defer stub.Close()
+
+	agent := testAgent(stub.Provider(), RetryPolicy{})
+	agent.Db = testDatabase(t, agent.Logger)
+	thread, err := agent.Db.CreateThread(modelpkg.ThreadModel{Name: "test", CreatedAt: time.Now()})
+	if err != nil {
+		t.Fatalf("CreateThread: %v", err)
+	}
+
+	ch, unsub, err := agent.Bus.Subscribe(eventpkg.EvtMessage, 8)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	defer unsub()
+
+	done := make(chan struct{})
+	go func() {
+		agent.Reply(context.Background(), MessageModel{Id: "prompt", ThreadId: thread.Id, Type: modelpkg.TyText, Text: "hola"})
+		close(done)
+	}()
+
+	// tras la primera vuelta (herramienta) se cancela la segunda petición //
+	var notice *MessageModel
+	for notice == nil {
+		select {
+		case evt := <-ch:
+			message := evt.Data.(MessageModel)
+			switch message.Type {
+			case modelpkg.TyTool:
+				agent.inflight.Cancel()
+			case modelpkg.TySystem:
+				notice = &message
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("no cancel notice")
+		}
+	}
+	<-done
+
+	if notice.Usage == nil || notice.Usage.InputTokens != 10 || notice.Usage.OutputTokens != 5 {
+		t.Fatalf("usage = %+v, want the tool round", notice.Usage)
+	}
+
+	// el aviso se guarda como cualquier mensaje y cuenta en los totales //
+	notice.CreatedAt = time.Now()
+	if _, err := agent.Db.CreateMessage(*notice); err != nil {
+		t.Fatalf("CreateMessage: %v", err)
+	}
+	usage, err := agent.Db.ThreadUsage(thread.Id)
+	if err != nil {
+		t.Fatalf("ThreadUsage: %v", err)
+	}
+	if usage.Total() != 15 {
+		t.Errorf("thread usage = %d, want 15", usage.Total())
+	}
+}
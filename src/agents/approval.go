@@ -0,0 +1,45 @@
+package agentspkg
+
+import (
+	"context"
+
+	eventpkg "main/src/event"
+	modelpkg "main/src/model"
+	toolspkg "main/src/tools"
+)
+
+type ApprovalModel = modelpkg.ApprovalModel
+
+// RequestApproval publica la petición y espera la respuesta (y/n) de la persona;
+// si el contexto termina antes, cuenta como denegada y se retira de la TUI
+func RequestApproval(ctx context.Context, bus *OptimizedBus, req ApprovalModel) bool {
+	ch, unsub, err := bus.Subscribe(eventpkg.EvtApproval, 16)
+	if err != nil {
+		return false
+	}
+	defer unsub()
+
+	if req.Id == "" {
+		req.Id = toolspkg.GenerateUUID()
+	}
+	req.Answered = false
+	bus.Publish(eventpkg.EvtApproval, req)
+
+	for {
+		select {
+		case <-ctx.Done():
+			req.Answered = true
+			req.Approved = false
+			bus.Publish(eventpkg.EvtApproval, req)
+			return false
+		case evt, ok := <-ch:
+			if !ok {
+				return false
+			}
+			answer, _ := evt.Data.(ApprovalModel)
+			if answer.Id == req.Id && answer.Answered {
+				return answer.Approved
+			}
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package agentspkg
+
+import (
+	"encoding/json"
+
+	cachepkg "main/src/cache"
+)
+
+// cacheTurn es un turno con todos sus campos; ChatMessage oculta en JSON
+// las llamadas a herramientas y no distinguiría peticiones distintas
+type cacheTurn struct {
+	Role       string
+	Content    string
+	ToolCalls  []ToolCall
+	ToolCallId string
+}
+
+// cacheKey identifica la petición para la caché; vacío = caché desactivada.
+// El modo streaming no cambia la respuesta y no forma parte de la clave. El id
+// del estado remoto es distinto en cada turno: en su lugar cuentan los turnos
+// locales que cubre (History), así aciertan también los turnos siguientes
+func (a *AAgent) cacheKey(req ProviderRequest) string {
+	if a.Cache == nil {
+		return ""
+	}
+	provider := a.ProviderName
+	if provider == "" {
+		provider = a.Provider.Name()
+	}
+
+	input := req.Input
+	if req.Conversation != "" {
+		input = append(append([]ChatMessage{}, req.History...), req.Input...)
+	}
+	turns := make([]cacheTurn, len(input))
+	for idx, msg := range input {
+		turns[idx] = cacheTurn(msg)
+	}
+	key, err := cachepkg.Key(struct {
+		Provider     string
+		Model        string
+		Instructions string
+		Input        []cacheTurn
+		Tools        []ToolSpec
+		Temperature  *float64
+		TopP         *float64
+		MaxOutput    *int
+		Reasoning    string
+	}{
+		Provider:     provider,
+		Model:        req.Model,
+		Instructions: req.Instructions,
+		Input:        turns,
+		Tools:        req.Tools,
+		Temperature:  req.Temperature,
+		TopP:         req.TopP,
+		MaxOutput:    req.MaxOutput,
+		Reasoning:    req.Reasoning,
+	})
+	if err != nil {
+		a.Logger.Error("Failed to build cache key", "agent", a.Name(), "error", err)
+		return ""
+	}
+	return key
+}
+
+// cached devuelve la respuesta guardada para la clave, marcada como Cached
+func (a *AAgent) cached(key string) (*ProviderResponse, bool) {
+	if key == "" {
+		return nil, false
+	}
+	data, ok := a.Cache.Get(key)
+	if !ok {
+		return nil, false
+	}
+	var response ProviderResponse
+	if err := json.Unmarshal([]byte(data), &response); err != nil {
+		return nil, false
+	}
+	response.Cached = true
+	return &response, true
+}
+
+// store guarda la respuesta del proveedor bajo la clave. El id y el estado
+// remoto pertenecen al hilo que hizo la petición y no se guardan: un acierto
+// desde otro hilo no debe enlazarlo con esa conversación
+func (a *AAgent) store(key string, response *ProviderResponse) {
+	if key == "" {
+		return
+	}
+	entry := *response
+	entry.Id = ""
+	entry.Conversation = ""
+	data, err := json.Marshal(entry)
+	if err == nil {
+		err = a.Cache.Put(key, response.Model, string(data))
+	}
+	if err != nil {
+		a.Logger.Error("Failed to cache response", "agent", a.Name(), "error", err)
+	}
+}
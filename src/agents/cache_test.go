@@ -0,0 +1,71 @@
+package agentspkg
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	cachepkg "main/src/cache"
+	configpkg "main/src/config"
+	eventpkg "main/src/event"
+	modelpkg "main/src/model"
+)
+
+func TestCacheHitsLaterTurnsWithRemoteState(t *testing.T) {
+	stub := NewStubServer()
+	defer stub.Close()
+
+	agent := testAgent(stub.Provider(), RetryPolicy{})
+	agent.Db = testDatabase(t, agent.Logger)
+	cache, err := cachepkg.NewCache(configpkg.CacheConfig{Enabled: true}, agent.Db)
+	if err != nil {
+		t.Fatalf("NewCache: %v", err)
+	}
+	agent.Cache = cache
+
+	messages, unsub, err := agent.Bus.Subscribe(eventpkg.EvtMessage, 8)
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	defer unsub()
+
+	// cada turno se guarda en la base de datos como lo haría la vista //
+	turn := func(threadId string, parentId string, text string) MessageModel {
+		prompt := MessageModel{
+			Id: threadId + "-" + text, ThreadId: threadId, ParentId: parentId,
+			Type: modelpkg.TyText, Source: modelpkg.ScHuman, Text: text, CreatedAt: time.Now(),
+		}
+		agent.Db.CreateMessage(prompt)
+		agent.Reply(context.Background(), prompt)
+		select {
+		case evt := <-messages:
+			reply := evt.Data.(MessageModel)
+			reply.ParentId = prompt.Id
+			reply.CreatedAt = time.Now()
+			agent.Db.CreateMessage(reply)
+			return reply
+		case <-time.After(3 * time.Second):
+			t.Fatalf("no reply to %q", text)
+		}
+		return MessageModel{}
+	}
+
+	// el hilo A guarda estado remoto tras el primer turno //
+	threadA, _ := agent.Db.CreateThread(modelpkg.ThreadModel{Name: "a", CreatedAt: time.Now()})
+	first := turn(threadA.Id, "", "hola")
+	if conversation, _ := agent.Db.GetConversation(threadA.Id, agent.Name()); conversation == "" {
+		t.Fatalf("thread A has no remote state after the first turn")
+	}
+	turn(threadA.Id, first.Id, "sigue")
+
+	// el hilo B repite la conversación: los dos turnos salen de la caché //
+	threadB, _ := agent.Db.CreateThread(modelpkg.ThreadModel{Name: "b", CreatedAt: time.Now()})
+	replay := turn(threadB.Id, "", "hola")
+	second := turn(threadB.Id, replay.Id, "sigue")
+	if !replay.Cached || !second.Cached {
+		t.Errorf("cached = %v, %v, want both turns from the cache", replay.Cached, second.Cached)
+	}
+	if requests := len(stub.Requests()); requests != 2 {
+		t.Errorf("provider requests = %d, want 2", requests)
+	}
+}
@@ -0,0 +1,100 @@
+package agentspkg
+
+import (
+	"context"
+	"sync"
+
+	eventpkg "main/src/event"
+)
+
+// Inflight guarda la cancelación de la petición en curso de un agente
+type Inflight struct {
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	active int           // peticiones en curso
+	idle   chan struct{} // se cierra cuando active vuelve a cero; nil = sin peticiones
+}
+
+// Begin crea el contexto de una petición; done libera la cancelación
+func (f *Inflight) Begin(ctx context.Context) (context.Context, func()) {
+	reqCtx, cancel := context.WithCancel(ctx)
+
+	f.mu.Lock()
+	f.cancel = cancel
+	if f.active == 0 {
+		f.idle = make(chan struct{})
+	}
+	f.active++
+	f.mu.Unlock()
+
+	return reqCtx, func() {
+		f.mu.Lock()
+		f.cancel = nil
+		f.active--
+		if f.active == 0 {
+			close(f.idle)
+			f.idle = nil
+		}
+		f.mu.Unlock()
+		cancel()
+	}
+}
+
+// Wait espera a que no quede ninguna petición en curso o a que venza ctx
+func (f *Inflight) Wait(ctx context.Context) error {
+	f.mu.Lock()
+	idle := f.idle
+	f.mu.Unlock()
+	if idle == nil {
+		return nil
+	}
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Cancel aborta la petición en curso; devuelve false si no había ninguna
+func (f *Inflight) Cancel() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.cancel == nil {
+		return false
+	}
+	f.cancel()
+	f.cancel = nil
+	return true
+}
+
+// ListenCancel atiende los EvtCancel dirigidos al agente (o a todos, "")
+// hasta que termina ctx
+func ListenCancel(ctx context.Context, bus *OptimizedBus, name string, f *Inflight) error {
+	ch, unsub, err := bus.Subscribe(eventpkg.EvtCancel, 8)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		defer unsub()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case evt, ok := <-ch:
+				if !ok {
+					return
+				}
+				target, _ := evt.Data.(string)
+				if target == "" || target == name {
+					f.Cancel()
+				}
+			}
+		}
+	}()
+
+	return nil
+}
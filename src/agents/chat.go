@@ -0,0 +1,235 @@
+package agentspkg
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// ChatProvider usa la API /chat/completions (gateways compatibles)
+type ChatProvider struct {
+	Url    string
+	Key    string
+	Client *http.Client
+}
+
+type ChatPayload struct {
+	Model       string         `json:"model"`
+	Messages    []ChatWire     `json:"messages"`
+	Tools       []ChatToolWire `json:"tools,omitempty"`
+	Temperature *float64       `json:"temperature,omitempty"`
+	TopP        *float64       `json:"top_p,omitempty"`
+	MaxTokens   *int           `json:"max_tokens,omitempty"`
+	Reasoning   string         `json:"reasoning_effort,omitempty"`
+	Stream      bool           `json:"stream,omitempty"`
+	// en streaming el consumo llega en un último chunk sin choices //
+	StreamOptions *struct {
+		IncludeUsage bool `json:"include_usage"`
+	} `json:"stream_options,omitempty"`
+}
+
+// ChatWire es un mensaje tal como lo espera /chat/completions
+type ChatWire struct {
+	Role       string         `json:"role"`
+	Content    string         `json:"content"`
+	ToolCalls  []ChatCallWire `json:"tool_calls,omitempty"`
+	ToolCallId string         `json:"tool_call_id,omitempty"`
+}
+
+type ChatCallWire struct {
+	Index    int    `json:"index"`
+	Id       string `json:"id,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Function struct {
+		Name      string `json:"name,omitempty"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
+}
+
+type ChatToolWire struct {
+	Type     string `json:"type"`
+	Function struct {
+		Name        string         `json:"name"`
+		Description string         `json:"description"`
+		Parameters  map[string]any `json:"parameters"`
+	} `json:"function"`
+}
+
+type ChatResponse struct {
+	ID      string `json:"id"`
+	Model   string `json:"model"`
+	Choices []struct {
+		Message ChatWire `json:"message"`
+		Delta   struct {
+			Content   string         `json:"content"`
+			ToolCalls []ChatCallWire `json:"tool_calls"`
+		} `json:"delta"`
+		FinishReason string `json:"finish_reason"`
+	} `json:"choices"`
+	Usage *ChatUsage `json:"usage"`
+}
+
+type ChatUsage struct {
+	PromptTokens        int `json:"prompt_tokens"`
+	CompletionTokens    int `json:"completion_tokens"`
+	PromptTokensDetails struct {
+		CachedTokens int `json:"cached_tokens"`
+	} `json:"prompt_tokens_details"`
+	CompletionTokensDetails struct {
+		ReasoningTokens int `json:"reasoning_tokens"`
+	} `json:"completion_tokens_details"`
+}
+
+func (u *ChatUsage) usage(model string) Usage {
+	if u == nil {
+		return Usage{Model: model}
+	}
+	return Usage{
+		Model:           model,
+		InputTokens:     u.PromptTokens,
+		CachedTokens:    u.PromptTokensDetails.CachedTokens,
+		OutputTokens:    u.CompletionTokens,
+		ReasoningTokens: u.CompletionTokensDetails.ReasoningTokens,
+	}
+}
+
+func (p *ChatProvider) Name() string { return "chat" }
+
+func (p *ChatProvider) Complete(
+	ctx context.Context,
+	req ProviderRequest,
+	onDelta func(text string),
+) (*ProviderResponse, error) {
+	payload := ChatPayload{
+		Model:       req.Model,
+		Messages:    chatMessages(withInstructions(req.Instructions, req.Input)),
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+		MaxTokens:   req.MaxOutput,
+		Reasoning:   req.Reasoning,
+		Stream:      req.Stream,
+	}
+	if req.Stream {
+		payload.StreamOptions = &struct {
+			IncludeUsage bool `json:"include_usage"`
+		}{IncludeUsage: true}
+	}
+	for _, spec := range req.Tools {
+		tool := ChatToolWire{Type: "function"}
+		tool.Function.Name = spec.Name
+		tool.Function.Description = spec.Description
+		tool.Function.Parameters = spec.Parameters
+		payload.Tools = append(payload.Tools, tool)
+	}
+
+	res, err := postJSON(ctx, p.Client, p.Url+"/chat/completions", p.Key, payload, req.Stream)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	result := &ProviderResponse{Model: req.Model}
+
+	if !isEventStream(res) {
+		response := ChatResponse{}
+		if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+			return nil, newNetworkError(err)
+		}
+		result.Id = response.ID
+		result.Model = response.Model
+		if len(response.Choices) > 0 {
+			result.Text = response.Choices[0].Message.Content
+			result.ToolCalls = chatToolCalls(response.Choices[0].Message.ToolCalls)
+		}
+		result.Usage = response.Usage.usage(result.Model)
+		return result, nil
+	}
+
+	var sb strings.Builder
+	var calls []ChatCallWire
+	var usage *ChatUsage
+	err = ReadSSE(res.Body, func(event string, data string) error {
+		chunk := ChatResponse{}
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return err
+		}
+		result.Id = chunk.ID
+		if chunk.Model != "" {
+			result.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+		if len(chunk.Choices) == 0 {
+			return nil
+		}
+		delta := chunk.Choices[0].Delta
+		if delta.Content != "" {
+			sb.WriteString(delta.Content)
+			if onDelta != nil {
+				onDelta(sb.String())
+			}
+		}
+		// Las llamadas llegan troceadas por índice //
+		for _, part := range delta.ToolCalls {
+			for len(calls) <= part.Index {
+				calls = append(calls, ChatCallWire{Index: len(calls)})
+			}
+			call := &calls[part.Index]
+			if part.Id != "" {
+				call.Id = part.Id
+			}
+			if part.Function.Name != "" {
+				call.Function.Name = part.Function.Name
+			}
+			call.Function.Arguments += part.Function.Arguments
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, newNetworkError(err)
+	}
+	result.Text = sb.String()
+	result.ToolCalls = chatToolCalls(calls)
+	result.Usage = usage.usage(result.Model)
+
+	return result, nil
+}
+
+// chatMessages traduce los turnos al formato de /chat/completions
+func chatMessages(input []ChatMessage) []ChatWire {
+	messages := make([]ChatWire, 0, len(input))
+	for _, msg := range input {
+		wire := ChatWire{Role: msg.Role, Content: msg.Content, ToolCallId: msg.ToolCallId}
+		for idx, call := range msg.ToolCalls {
+			item := ChatCallWire{Index: idx, Id: call.Id, Type: "function"}
+			item.Function.Name = call.Name
+			item.Function.Arguments = call.Arguments
+			wire.ToolCalls = append(wire.ToolCalls, item)
+		}
+		messages = append(messages, wire)
+	}
+	return messages
+}
+
+func chatToolCalls(wire []ChatCallWire) []ToolCall {
+	var calls []ToolCall
+	for _, call := range wire {
+		calls = append(calls, ToolCall{
+			Id:        call.Id,
+			Name:      call.Function.Name,
+			Arguments: call.Function.Arguments,
+		})
+	}
+	return calls
+}
+
+// withInstructions antepone las instrucciones como mensaje "system"
+func withInstructions(instructions string, input []ChatMessage) []ChatMessage {
+	if instructions == "" {
+		return input
+	}
+	messages := []ChatMessage{{Role: "system", Content: instructions}}
+	return append(messages, input...)
+}
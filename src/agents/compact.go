@@ -0,0 +1,106 @@
+package agentspkg
+
+import (
+	"context"
+	"strings"
+
+	eventpkg "main/src/event"
+	modelpkg "main/src/model"
+	toolspkg "main/src/tools"
+)
+
+const compactInstructions = `Resume la conversación que recibes para que sirva de contexto
+a un asistente que continuará el diálogo. Conserva hechos, decisiones, datos concretos
+(nombres, rutas, cifras) y preguntas pendientes. Responde solo con el resumen.`
+
+// EstimateTokens aproxima los tokens de los turnos (≈ 4 caracteres por token)
+func EstimateTokens(input []ChatMessage) int {
+	chars := 0
+	for _, msg := range input {
+		chars += len(msg.Role) + len(msg.Content)
+		for _, call := range msg.ToolCalls {
+			chars += len(call.Name) + len(call.Arguments)
+		}
+	}
+	return chars / 4
+}
+
+// Compact resume los mensajes antiguos del hilo cuando el prompt estimado supera
+// CompactAt; publica el resumen (TySummary) y devuelve el nuevo contexto.
+// Los mensajes originales se conservan en la base de datos
+func (a *AAgent) Compact(ctx context.Context, msg MessageModel) ([]ChatMessage, bool) {
+	if a.CompactAt <= 0 || a.Db == nil || msg.ThreadId == "" {
+		return nil, false
+	}
+	keep := a.CompactKeep
+	if keep <= 0 {
+		keep = 6
+	}
+
+	summary, items := ThreadHistory(a.Db, a.Name(), msg)
+	if len(items) <= keep {
+		return nil, false
+	}
+
+	// con estado remoto el tamaño real lo da la última respuesta del agente //
+	size := EstimateTokens(HistoryInput(a.Name(), summary, items))
+	for _, item := range items {
+		if item.WrittenBy == a.Name() && item.Usage != nil && item.Usage.InputTokens > size {
+			size = item.Usage.InputTokens
+		}
+	}
+	if size < a.CompactAt {
+		return nil, false
+	}
+	if a.Budget != nil && a.Budget.Check(msg.ThreadId) != nil {
+		return nil, false
+	}
+
+	older, recent := items[:len(items)-keep], items[len(items)-keep:]
+
+	var sb strings.Builder
+	if summary != nil {
+		sb.WriteString("Resumen previo:\n" + summary.Text + "\n\n")
+	}
+	for _, item := range older {
+		author := "Persona"
+		if item.Source == modelpkg.ScAssistant {
+			author = item.WrittenBy
+		}
+		sb.WriteString(author + ": " + UserContent(item) + "\n\n")
+	}
+
+	request := ProviderRequest{
+		Model:        a.Model,
+		Instructions: compactInstructions,
+		Input:        []ChatMessage{{Role: "user", Content: sb.String()}},
+	}
+	a.applySettings(&request, msg.ThreadId)
+	response, err := a.Request(ctx, request, nil)
+	if err != nil || strings.TrimSpace(response.Text) == "" {
+		a.Logger.Error("Failed to compact thread", "agent", a.Name(), "thread", msg.ThreadId, "error", err)
+		return nil, false
+	}
+
+	compacted := MessageModel{
+		Id:        toolspkg.GenerateUUID(),
+		ThreadId:  msg.ThreadId,
+		ReplyTo:   older[len(older)-1].Id,
+		Type:      modelpkg.TySummary,
+		Source:    modelpkg.ScAssistant,
+		WrittenBy: a.Name(),
+		Text:      strings.TrimSpace(response.Text),
+	}
+	if response.Usage.Total() > 0 {
+		usage := response.Usage
+		compacted.Usage = &usage
+	}
+	a.Bus.Publish(eventpkg.EvtMessage, compacted)
+
+	// el estado remoto ya no sirve: el siguiente turno parte del resumen //
+	a.Db.SetConversation(msg.ThreadId, a.Name(), "")
+
+	a.Logger.Info("Thread compacted", "agent", a.Name(), "thread", msg.ThreadId, "messages", len(older), "tokens", size)
+	input := HistoryInput(a.Name(), &compacted, recent)
+	return append(input, ChatMessage{Role: "user", Content: UserContent(msg)}), true
+}
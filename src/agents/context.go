@@ -0,0 +1,113 @@
+package agentspkg
+
+import (
+	"strings"
+
+	databasepkg "main/src/database"
+	modelpkg "main/src/model"
+)
+
+type Database = databasepkg.Database
+
+// ThreadContext arma el contexto que ve el modelo para el hilo del mensaje:
+// el estado remoto guardado para el agente o, si no existe, el historial local
+func ThreadContext(db *Database, agent string, history bool, msg MessageModel) (string, []ChatMessage) {
+	current := ChatMessage{Role: "user", Content: UserContent(msg)}
+	if db == nil || msg.ThreadId == "" {
+		return "", []ChatMessage{current}
+	}
+
+	if !history {
+		conversation, _ := db.GetConversation(msg.ThreadId, agent)
+		if conversation != "" {
+			return conversation, []ChatMessage{current}
+		}
+	}
+
+	summary, items := ThreadHistory(db, agent, msg)
+	return "", append(HistoryInput(agent, summary, items), current)
+}
+
+// ThreadHistory devuelve el último resumen del agente (o nil) y los mensajes
+// de texto posteriores a lo que resume, sin incluir msg
+func ThreadHistory(db *Database, agent string, msg MessageModel) (*MessageModel, []MessageModel) {
+	// la rama que lleva al mensaje, aunque la activa haya cambiado después //
+	messages, _ := db.ListBranch(msg.ThreadId, msg.ParentId, false)
+
+	var summary *MessageModel
+	for idx := range messages {
+		if messages[idx].Type == modelpkg.TySummary && messages[idx].WrittenBy == agent {
+			summary = &messages[idx]
+		}
+	}
+
+	// lo resumido (hasta ReplyTo inclusive) ya no se envía; si el mensaje
+	// resumido ya no existe, se envía todo //
+	start := 0
+	if summary != nil {
+		covered := -1
+		for idx, item := range messages {
+			if item.Id == summary.ReplyTo {
+				covered = idx
+			}
+		}
+		if covered < 0 {
+			summary = nil
+		}
+		start = covered + 1
+	}
+
+	items := []MessageModel{}
+	for _, item := range messages[start:] {
+		// las llamadas a herramientas no forman parte del historial //
+		if item.Id == msg.Id || item.Type != modelpkg.TyText {
+			continue
+		}
+		items = append(items, item)
+	}
+
+	return summary, items
+}
+
+// HistoryInput traduce el resumen y los mensajes a turnos para el proveedor
+func HistoryInput(agent string, summary *MessageModel, items []MessageModel) []ChatMessage {
+	input := []ChatMessage{}
+	if summary != nil {
+		input = append(input, ChatMessage{
+			Role:    "system",
+			Content: "Resumen de la conversación anterior:\n" + summary.Text,
+		})
+	}
+	for _, item := range items {
+		switch item.Source {
+		case modelpkg.ScHuman:
+			input = append(input, ChatMessage{Role: "user", Content: UserContent(item)})
+		case modelpkg.ScAssistant:
+			// solo las respuestas propias; las de otros agentes no son su turno //
+			if item.WrittenBy == agent {
+				input = append(input, ChatMessage{Role: "assistant", Content: item.Text})
+			}
+		}
+	}
+	return input
+}
+
+// UserContent es el texto del mensaje humano seguido de sus adjuntos,
+// cada uno en un bloque con su ruta
+func UserContent(msg MessageModel) string {
+	if len(msg.Attachments) == 0 {
+		return msg.Text
+	}
+
+	var sb strings.Builder
+	sb.WriteString(msg.Text)
+	for _, attachment := range msg.Attachments {
+		sb.WriteString("\n\n--- file: " + attachment.Path + " ---\n")
+		sb.WriteString(attachment.Content)
+		if !strings.HasSuffix(attachment.Content, "\n") {
+			sb.WriteString("\n")
+		}
+		sb.WriteString("--- end of file: " + attachment.Path + " ---")
+	}
+	return sb.String()
+}
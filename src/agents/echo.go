@@ -6,15 +6,22 @@ import (
 
 	eventpkg "main/src/event"
 	modelpkg "main/src/model"
+	toolspkg "main/src/tools"
 )
 
 type EchoAgent struct {
-	Logger  *slog.Logger
-	Bus     *OptimizedBus
-	Command *Command
+	Logger    *slog.Logger
+	Bus       *OptimizedBus
+	Command   *Command
+	AgentName string // vacío = "echo"
 }
 
-func (a *EchoAgent) Name() string { return "echo" }
+func (a *EchoAgent) Name() string {
+	if a.AgentName != "" {
+		return a.AgentName
+	}
+	return "echo"
+}
 
 func (a *EchoAgent) Start(ctx context.Context) error {
 	ch, unsub, err := a.Bus.Subscribe(eventpkg.EvtMessage, 64)
@@ -37,12 +44,15 @@ func (a *EchoAgent) Start(ctx context.Context) error {
 			case modelpkg.TySystem:
 				//
 			case modelpkg.TyText:
+				// solo responde a personas que se dirigen a él //
+				if msg.Source != modelpkg.ScHuman || !msg.IsAddressedTo(a.Name()) {
+					break
+				}
 				if ok, _ := a.Command.IsCommand(msg.Text); !ok {
 					message := MessageModel{
-						/**
-						 * TODO: add thread_id
-						 */
-						ThreadId:  "",
+						Id:        toolspkg.GenerateUUID(),
+						ThreadId:  msg.ThreadId,
+						ReplyTo:   msg.Id,
 						Type:      modelpkg.TyText,
 						Source:    modelpkg.ScAssistant,
 						WrittenBy: a.Name(),
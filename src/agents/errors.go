@@ -0,0 +1,109 @@
+package agentspkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ProviderError describe un fallo del proveedor y si vale la pena reintentar
+type ProviderError struct {
+	StatusCode int           // 0 si el fallo es de red
+	Status     string        // status HTTP o descripción del fallo
+	RetryAfter time.Duration // espera pedida por el servidor (Retry-After)
+	Err        error
+}
+
+func (e *ProviderError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Status, e.Err)
+	}
+	return e.Status
+}
+
+func (e *ProviderError) Unwrap() error { return e.Err }
+
+// Transient indica si el error es temporal: timeouts, red, 408, 429 o 5xx
+func (e *ProviderError) Transient() bool {
+	switch {
+	case e.StatusCode == 0:
+		return true
+	case e.StatusCode == http.StatusRequestTimeout,
+		e.StatusCode == http.StatusTooManyRequests:
+		return true
+	case e.StatusCode >= 500:
+		return true
+	}
+	return false
+}
+
+// newStatusError construye el error a partir de una respuesta no exitosa
+func newStatusError(res *http.Response, detail string) *ProviderError {
+	var err error
+	if detail != "" {
+		err = errors.New(detail)
+	}
+	return &ProviderError{
+		StatusCode: res.StatusCode,
+		Status:     res.Status,
+		RetryAfter: parseRetryAfter(res.Header.Get("Retry-After"), time.Now()),
+		Err:        err,
+	}
+}
+
+// newNetworkError envuelve un fallo de transporte o de lectura del stream
+func newNetworkError(err error) error {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	var perr *ProviderError
+	if errors.As(err, &perr) {
+		return err
+	}
+	var nerr net.Error
+	if errors.As(err, &nerr) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return &ProviderError{Status: "network error", Err: err}
+	}
+	return err
+}
+
+// IsTransient indica si err merece un reintento
+func IsTransient(err error) bool {
+	var perr *ProviderError
+	if errors.As(err, &perr) {
+		return perr.Transient()
+	}
+	return false
+}
+
+// parseRetryAfter acepta segundos o una fecha HTTP
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
+
+// FailureText describe el fallo para mostrarlo como mensaje de sistema
+func FailureText(agent string, err error) string {
+	var perr *ProviderError
+	if errors.As(err, &perr) {
+		kind := "permanente"
+		if perr.Transient() {
+			kind = "temporal, reintentos agotados"
+		}
+		return fmt.Sprintf("**Error en %s** (%s): %s", agent, kind, perr.Error())
+	}
+	return fmt.Sprintf("**Error en %s**: %s", agent, err.Error())
+}
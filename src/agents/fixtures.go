@@ -0,0 +1,243 @@
+package agentspkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Modos del transporte de fixtures
+const (
+	FixtureRecord = "record" // llama al proveedor real y guarda cada par petición/respuesta
+	FixtureReplay = "replay" // responde desde el archivo, sin red
+)
+
+// Fixture es un par petición/respuesta grabado; la petición se guarda normalizada
+type Fixture struct {
+	Method      string          `json:"method"`
+	Path        string          `json:"path"`
+	Request     json.RawMessage `json:"request"` // los cuerpos que no son JSON van como cadena
+	Status      int             `json:"status"`
+	ContentType string          `json:"content_type,omitempty"`
+	RetryAfter  string          `json:"retry_after,omitempty"`
+	Response    string          `json:"response"` // cuerpo tal cual (JSON o SSE)
+}
+
+// FixtureTransport es un http.RoundTripper que graba o reproduce las llamadas
+// de los proveedores. Las peticiones se comparan por método, ruta y cuerpo
+// JSON normalizado; si varias coinciden se consumen en orden (p. ej. un 429
+// seguido del reintento) y la última se repite cuando se agotan
+type FixtureTransport struct {
+	Mode string
+	Path string
+	Next http.RoundTripper // solo en modo record; nil = http.DefaultTransport
+
+	mu       sync.Mutex
+	fixtures []Fixture
+	used     map[int]bool
+}
+
+// NewFixtureTransport carga el archivo en modo replay; en modo record lo
+// reescribe desde cero con las llamadas de esta ejecución
+func NewFixtureTransport(mode string, path string, next http.RoundTripper) (*FixtureTransport, error) {
+	if path == "" {
+		return nil, errors.New("fixtures file not configured")
+	}
+	t := &FixtureTransport{Mode: mode, Path: path, Next: next, used: map[int]bool{}}
+
+	switch mode {
+	case FixtureReplay:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &t.fixtures); err != nil {
+			return nil, fmt.Errorf("fixtures %s: %w", path, err)
+		}
+	case FixtureRecord:
+		if t.Next == nil {
+			t.Next = http.DefaultTransport
+		}
+	default:
+		return nil, fmt.Errorf("fixture mode %q not supported", mode)
+	}
+	return t, nil
+}
+
+func (t *FixtureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	normalized := fixtureRequest(NormalizeBody(body))
+
+	if t.Mode == FixtureReplay {
+		return t.replay(req, normalized), nil
+	}
+	return t.record(req, body, normalized)
+}
+
+// replay busca la respuesta grabada; sin coincidencia responde 404 para
+// que el fallo no se trate como temporal y no se reintente
+func (t *FixtureTransport) replay(req *http.Request, normalized []byte) *http.Response {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	last := -1
+	for idx, fixture := range t.fixtures {
+		if fixture.Method != req.Method || fixture.Path != req.URL.Path ||
+			!bytes.Equal(NormalizeBody(fixture.Request), normalized) {
+			continue
+		}
+		last = idx
+		if !t.used[idx] {
+			t.used[idx] = true
+			return fixture.httpResponse(req)
+		}
+	}
+	if last >= 0 {
+		return t.fixtures[last].httpResponse(req)
+	}
+
+	missing := Fixture{
+		Status:      http.StatusNotFound,
+		ContentType: "text/plain",
+		Response:    fmt.Sprintf("no fixture for %s %s %s", req.Method, req.URL.Path, normalized),
+	}
+	return missing.httpResponse(req)
+}
+
+// record reenvía la petición y copia la respuesta mientras se lee, así los
+// streams llegan al proveedor sin esperar; se guarda al terminar el cuerpo
+func (t *FixtureTransport) record(req *http.Request, body []byte, normalized []byte) (*http.Response, error) {
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	res, err := t.Next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	fixture := Fixture{
+		Method:      req.Method,
+		Path:        req.URL.Path,
+		Request:     normalized,
+		Status:      res.StatusCode,
+		ContentType: res.Header.Get("Content-Type"),
+		RetryAfter:  res.Header.Get("Retry-After"),
+	}
+	res.Body = &recordingBody{ReadCloser: res.Body, done: func(data []byte) error {
+		fixture.Response = string(data)
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		t.fixtures = append(t.fixtures, fixture)
+		return t.save()
+	}}
+	return res, nil
+}
+
+// recordingBody copia lo leído del cuerpo y lo entrega a done al llegar a EOF;
+// si se cierra antes se lee el resto, y una respuesta cortada no se graba
+type recordingBody struct {
+	io.ReadCloser
+	data     bytes.Buffer
+	done     func(data []byte) error
+	finished bool
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.data.Write(p[:n])
+	if err == io.EOF && !b.finished {
+		b.finished = true
+		if saveErr := b.done(b.data.Bytes()); saveErr != nil {
+			return n, saveErr
+		}
+	}
+	return n, err
+}
+
+func (b *recordingBody) Close() error {
+	if !b.finished {
+		b.finished = true
+		if _, err := b.data.ReadFrom(b.ReadCloser); err == nil {
+			if err := b.done(b.data.Bytes()); err != nil {
+				b.ReadCloser.Close()
+				return err
+			}
+		}
+	}
+	return b.ReadCloser.Close()
+}
+
+func (t *FixtureTransport) save() error {
+	data, err := json.MarshalIndent(t.fixtures, "", "  ")
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(t.Path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(t.Path, data, 0o644)
+}
+
+func (f Fixture) httpResponse(req *http.Request) *http.Response {
+	header := http.Header{}
+	if f.ContentType != "" {
+		header.Set("Content-Type", f.ContentType)
+	}
+	if f.RetryAfter != "" {
+		header.Set("Retry-After", f.RetryAfter)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", f.Status, http.StatusText(f.Status)),
+		StatusCode:    f.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader([]byte(f.Response))),
+		ContentLength: int64(len(f.Response)),
+		Request:       req,
+	}
+}
+
+// fixtureRequest deja la petición normalizada lista para el archivo: si no es
+// JSON se guarda como cadena, porque json.RawMessage tiene que ser válido
+func fixtureRequest(normalized []byte) json.RawMessage {
+	if json.Valid(normalized) {
+		return normalized
+	}
+	data, _ := json.Marshal(string(normalized))
+	return data
+}
+
+// NormalizeBody reescribe un cuerpo JSON con las claves ordenadas y sin espacios;
+// si no es JSON se devuelve tal cual
+func NormalizeBody(body []byte) []byte {
+	if len(bytes.TrimSpace(body)) == 0 {
+		return []byte("null")
+	}
+	var value any
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return body
+	}
+	normalized, err := json.Marshal(value)
+	if err != nil {
+		return body
+	}
+	return normalized
+}
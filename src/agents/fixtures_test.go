@@ -0,0 +1,95 @@
+package agentspkg
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFixturesRecordThenReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fixtures.json")
+	request := ProviderRequest{Model: "stub-model", Input: []ChatMessage{{Role: "user", Content: "hola"}}}
+
+	stub := NewStubServer(StubReply{Text: "grabado"})
+	recorder, err := NewProvider(ProviderConfig{Url: stub.URL, Mode: FixtureRecord, Fixtures: path}, stub.Client())
+	if err != nil {
+		t.Fatalf("NewProvider record: %v", err)
+	}
+	if _, err := recorder.Complete(context.Background(), request, nil); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	stub.Close()
+
+	// sin servidor: la respuesta sale del archivo //
+	replayer, err := NewProvider(ProviderConfig{Mode: FixtureReplay, Fixtures: path}, nil)
+	if err != nil {
+		t.Fatalf("NewProvider replay: %v", err)
+	}
+	response, err := replayer.Complete(context.Background(), request, nil)
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if response.Text != "grabado" {
+		t.Errorf("text = %q", response.Text)
+	}
+
+	// una petición distinta no coincide: 404 permanente, sin reintentos //
+	request.Input[0].Content = "otra cosa"
+	_, err = replayer.Complete(context.Background(), request, nil)
+	var perr *ProviderError
+	if !errors.As(err, &perr) || perr.StatusCode != http.StatusNotFound {
+		t.Fatalf("mismatch err = %v, want ProviderError 404", err)
+	}
+	if IsTransient(err) {
+		t.Errorf("fixture mismatch must not be transient")
+	}
+}
+
+func TestFixturesRecordStreamAndPlainBody(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fixtures.json")
+	request := ProviderRequest{Model: "stub-model", Input: []ChatMessage{{Role: "user", Content: "hola"}}, Stream: true}
+
+	// el proveedor recibe los deltas mientras se graba el stream //
+	stub := NewStubServer(StubReply{Text: "grabado en streaming"})
+	defer stub.Close()
+	transport, err := NewFixtureTransport(FixtureRecord, path, stub.Client().Transport)
+	if err != nil {
+		t.Fatalf("NewFixtureTransport: %v", err)
+	}
+	client := &http.Client{Transport: transport}
+	recorder := &ResponsesProvider{Url: stub.URL, Key: "stub", Client: client}
+	var deltas []string
+	if _, err := recorder.Complete(context.Background(), request, func(text string) { deltas = append(deltas, text) }); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+	if len(deltas) < 2 {
+		t.Errorf("deltas = %q, want one per word", deltas)
+	}
+
+	// un cuerpo que no es JSON se guarda como cadena y el archivo sigue siendo válido //
+	res, err := client.Post(stub.URL+"/responses", "text/plain", strings.NewReader("no es json"))
+	if err != nil {
+		t.Fatalf("plain post: %v", err)
+	}
+	io.Copy(io.Discard, res.Body)
+	if err := res.Body.Close(); err != nil {
+		t.Fatalf("plain body close: %v", err)
+	}
+
+	replayer, err := NewProvider(ProviderConfig{Mode: FixtureReplay, Fixtures: path}, nil)
+	if err != nil {
+		t.Fatalf("NewProvider replay: %v", err)
+	}
+	deltas = nil
+	response, err := replayer.Complete(context.Background(), request, func(text string) { deltas = append(deltas, text) })
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if response.Text != "grabado en streaming" || len(deltas) < 2 {
+		t.Errorf("replay = %q, deltas %q", response.Text, deltas)
+	}
+}
@@ -0,0 +1,301 @@
+package agentspkg
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"sort"
+	"time"
+
+	budgetpkg "main/src/budget"
+	cachepkg "main/src/cache"
+	configpkg "main/src/config"
+	indexpkg "main/src/index"
+	managerpkg "main/src/manager"
+	routerpkg "main/src/router"
+	settingspkg "main/src/settings"
+)
+
+type AgentConfig = configpkg.AgentConfig
+
+// Deps son las dependencias compartidas por todos los agentes
+type Deps struct {
+	Logger   *slog.Logger
+	Bus      *OptimizedBus
+	Command  *Command
+	Db       *Database
+	Tools    *ToolRegistry
+	Budget   *budgetpkg.Budget
+	Settings *settingspkg.Settings
+	Index    *indexpkg.Index
+	Cache    *cachepkg.Cache
+}
+
+// Build crea un agente a partir de su declaración en config
+func Build(conf *Config, def AgentConfig, deps Deps) (managerpkg.Agent, error) {
+	switch def.Kind {
+	case "llm", "":
+		provider, model, err := ProviderFor(conf, def)
+		if err != nil {
+			return nil, err
+		}
+		var tools []Tool
+		if len(def.Tools) > 0 {
+			if deps.Tools == nil {
+				return nil, errors.New("tools not available")
+			}
+			if tools, err = deps.Tools.Select(def.Tools); err != nil {
+				return nil, err
+			}
+		}
+		return &AAgent{
+			Logger:       deps.Logger,
+			Bus:          deps.Bus,
+			Command:      deps.Command,
+			Db:           deps.Db,
+			AgentName:    def.Name,
+			Provider:     provider,
+			Model:        model,
+			Instructions: def.Instructions,
+			Temperature:  def.Temperature,
+			History:      def.Context == "history",
+			Tools:        tools,
+			MaxRounds:    def.MaxToolRounds,
+			Budget:       deps.Budget,
+			CompactAt:    def.CompactAt,
+			CompactKeep:  def.CompactKeep,
+			Settings:     deps.Settings,
+			Index:        deps.Index,
+			Cache:        deps.Cache,
+			ProviderName: def.Provider,
+		}, nil
+
+	case "echo":
+		return &EchoAgent{
+			Logger:    deps.Logger,
+			Bus:       deps.Bus,
+			Command:   deps.Command,
+			AgentName: def.Name,
+		}, nil
+
+	case "process":
+		if def.Command == "" {
+			return nil, errors.New("process agent without command")
+		}
+		return &ProcessAgent{
+			Logger:    deps.Logger,
+			Bus:       deps.Bus,
+			Command:   deps.Command,
+			AgentName: def.Name,
+			Path:      def.Command,
+			Args:      def.Args,
+			Dir:       def.Dir,
+			Env:       def.Env,
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("agent kind %q not supported", def.Kind)
+	}
+}
+
+// BuildOptions traduce la política de reinicio declarada para el Manager
+func BuildOptions(def AgentConfig) (managerpkg.Options, error) {
+	opts := managerpkg.Options{AutoRestart: true}
+	if def.AutoRestart != nil {
+		opts.AutoRestart = *def.AutoRestart
+	}
+
+	var err error
+	if def.MinBackoff != "" {
+		if opts.MinBackoff, err = time.ParseDuration(def.MinBackoff); err != nil {
+			return opts, fmt.Errorf("min_backoff: %w", err)
+		}
+	}
+	if def.MaxBackoff != "" {
+		if opts.MaxBackoff, err = time.ParseDuration(def.MaxBackoff); err != nil {
+			return opts, fmt.Errorf("max_backoff: %w", err)
+		}
+	}
+
+	// Supervisión: grupo, estrategia e intensidad de reinicios //
+	opts.Group = def.Group
+	opts.MaxRestarts = def.MaxRestarts
+	switch def.Strategy {
+	case "", managerpkg.OneForOne, managerpkg.OneForAll, managerpkg.RestForOne:
+		opts.Strategy = def.Strategy
+	default:
+		return opts, fmt.Errorf("strategy %q not supported", def.Strategy)
+	}
+	if def.RestartWindow != "" {
+		if opts.Window, err = time.ParseDuration(def.RestartWindow); err != nil {
+			return opts, fmt.Errorf("restart_window: %w", err)
+		}
+	}
+	if def.StableAfter != "" {
+		if opts.StableAfter, err = time.ParseDuration(def.StableAfter); err != nil {
+			return opts, fmt.Errorf("stable_after: %w", err)
+		}
+	}
+	if def.StopTimeout != "" {
+		if opts.StopTimeout, err = time.ParseDuration(def.StopTimeout); err != nil {
+			return opts, fmt.Errorf("stop_timeout: %w", err)
+		}
+	}
+
+	return opts, nil
+}
+
+// declared es lo que se aplicó de un agente; si cambia, el agente se recrea
+type declared struct {
+	agent    AgentConfig
+	provider ProviderConfig
+}
+
+// Loader mantiene el Manager sincronizado con los agentes de config.yaml
+type Loader struct {
+	deps    Deps
+	mgr     *managerpkg.Manager
+	config  *Config
+	path    string
+	applied map[string]declared
+	router  *routerpkg.Router // nil = las reglas de enrutado no se recargan
+
+	runtime  map[string]AgentConfig // creados con `/agent add`
+	disabled map[string]bool        // deshabilitados con `/agent disable`
+	restored bool
+}
+
+func NewLoader(config *Config, path string, mgr *managerpkg.Manager, deps Deps) *Loader {
+	return &Loader{
+		deps:    deps,
+		mgr:     mgr,
+		config:  config,
+		path:    path,
+		applied: map[string]declared{},
+
+		runtime:  map[string]AgentConfig{},
+		disabled: map[string]bool{},
+	}
+}
+
+// SetRouter recarga también las reglas de enrutado en cada Reload
+func (l *Loader) SetRouter(router *routerpkg.Router) {
+	l.router = router
+}
+
+// Apply registra los agentes nuevos, recrea los modificados (rearrancándolos
+// si estaban en ejecución) y da de baja los que ya no están declarados. La
+// primera vez restaura además los agentes de `/agent add` y los deshabilitados
+func (l *Loader) Apply(conf *Config) (added []string, updated []string, removed []string, err error) {
+	var errs []error
+	seen := map[string]bool{}
+	restored := l.restore()
+
+	for _, def := range conf.Config.Agents {
+		if def.Name == "" {
+			errs = append(errs, errors.New("agent without name"))
+			continue
+		}
+		seen[def.Name] = true
+
+		provider, _ := conf.Provider(def.Provider)
+		decl := declared{agent: def, provider: provider}
+		prev, exists := l.applied[def.Name]
+		if exists && reflect.DeepEqual(prev, decl) {
+			continue
+		}
+
+		agent, err := Build(conf, def, l.deps)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("agent %s: %w", def.Name, err))
+			continue
+		}
+		opts, err := BuildOptions(def)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("agent %s: %w", def.Name, err))
+			continue
+		}
+		opts.Disabled = l.disabled[def.Name]
+
+		// config.yaml manda sobre un agente de `/agent add` con el mismo nombre //
+		if _, added := l.runtime[def.Name]; added {
+			errs = append(errs, fmt.Errorf("agent %s: declared in config.yaml, replaces the one added with /agent add", def.Name))
+			delete(l.runtime, def.Name)
+			if l.deps.Db != nil {
+				l.deps.Db.SaveAgentRecord(AgentRecordModel{Name: def.Name, Enabled: !l.disabled[def.Name]})
+			}
+		}
+
+		running := l.mgr.IsRunning(def.Name)
+		if l.mgr.Has(def.Name) {
+			// si la instancia anterior no sale, no se registra una segunda //
+			if err := l.mgr.Unregister(def.Name); err != nil {
+				errs = append(errs, fmt.Errorf("agent %s: %w", def.Name, err))
+				continue
+			}
+		}
+		l.mgr.RegisterWithOptions(agent, opts)
+		if running {
+			l.mgr.StartAgent(def.Name)
+		}
+		l.applied[def.Name] = decl
+
+		if exists {
+			updated = append(updated, def.Name)
+		} else {
+			added = append(added, def.Name)
+		}
+	}
+
+	// Agentes de `/agent add` guardados en ejecuciones anteriores //
+	for _, def := range restored {
+		if seen[def.Name] {
+			errs = append(errs, fmt.Errorf("agent %s: declared in config.yaml, replaces the one added with /agent add", def.Name))
+			if l.deps.Db != nil {
+				l.deps.Db.SaveAgentRecord(AgentRecordModel{Name: def.Name, Enabled: !l.disabled[def.Name]})
+			}
+			continue
+		}
+		if err := l.register(conf, def); err != nil {
+			errs = append(errs, fmt.Errorf("agent %s: %w", def.Name, err))
+			continue
+		}
+		l.runtime[def.Name] = def
+		added = append(added, def.Name)
+	}
+
+	for name := range l.applied {
+		if seen[name] {
+			continue
+		}
+		if err := l.mgr.Unregister(name); err != nil {
+			errs = append(errs, fmt.Errorf("agent %s: %w", name, err))
+			continue
+		}
+		delete(l.applied, name)
+		removed = append(removed, name)
+	}
+	sort.Strings(removed)
+
+	return added, updated, removed, errors.Join(errs...)
+}
+
+// Reload vuelve a leer config.yaml y aplica, sin reiniciar el programa, los
+// cambios de agentes y de enrutado; el resto espera al próximo arranque
+func (l *Loader) Reload() (added []string, updated []string, removed []string, err error) {
+	conf, err := configpkg.ReadConfig(l.path)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	added, updated, removed, err = l.Apply(conf)
+	// el Config compartido no se toca (lo leen otras goroutines): el loader
+	// guarda su copia y el router recarga sus reglas //
+	l.config = conf
+	if l.router != nil {
+		l.router.Reload(conf)
+	}
+
+	return added, updated, removed, err
+}
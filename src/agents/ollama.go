@@ -0,0 +1,160 @@
+package agentspkg
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	toolspkg "main/src/tools"
+)
+
+// OllamaProvider usa la API /api/chat de servidores locales tipo Ollama
+type OllamaProvider struct {
+	Url    string
+	Client *http.Client
+}
+
+type OllamaPayload struct {
+	Model    string         `json:"model"`
+	Messages []OllamaWire   `json:"messages"`
+	Tools    []ChatToolWire `json:"tools,omitempty"`
+	Options  map[string]any `json:"options,omitempty"`
+	Stream   bool           `json:"stream"`
+}
+
+// OllamaWire es un mensaje de /api/chat; los argumentos van como objeto JSON
+type OllamaWire struct {
+	Role      string `json:"role"`
+	Content   string `json:"content"`
+	ToolCalls []struct {
+		Function struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		} `json:"function"`
+	} `json:"tool_calls,omitempty"`
+}
+
+type OllamaResponse struct {
+	Model   string     `json:"model"`
+	Message OllamaWire `json:"message"`
+	Done    bool       `json:"done"`
+	Error   string     `json:"error"`
+	// consumo, presente en el último chunk //
+	PromptEvalCount int `json:"prompt_eval_count"`
+	EvalCount       int `json:"eval_count"`
+}
+
+func (p *OllamaProvider) Name() string { return "ollama" }
+
+func (p *OllamaProvider) Complete(
+	ctx context.Context,
+	req ProviderRequest,
+	onDelta func(text string),
+) (*ProviderResponse, error) {
+	payload := OllamaPayload{
+		Model:    req.Model,
+		Messages: ollamaMessages(withInstructions(req.Instructions, req.Input)),
+		Stream:   req.Stream,
+	}
+	for _, spec := range req.Tools {
+		tool := ChatToolWire{Type: "function"}
+		tool.Function.Name = spec.Name
+		tool.Function.Description = spec.Description
+		tool.Function.Parameters = spec.Parameters
+		payload.Tools = append(payload.Tools, tool)
+	}
+	payload.Options = map[string]any{}
+	if req.Temperature != nil {
+		payload.Options["temperature"] = *req.Temperature
+	}
+	if req.TopP != nil {
+		payload.Options["top_p"] = *req.TopP
+	}
+	if req.MaxOutput != nil {
+		payload.Options["num_predict"] = *req.MaxOutput
+	}
+
+	res, err := postJSON(ctx, p.Client, p.Url+"/api/chat", "", payload, false)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	result := &ProviderResponse{Model: req.Model}
+
+	// En streaming cada línea es un objeto JSON (NDJSON) //
+	var sb strings.Builder
+	scanner := bufio.NewScanner(res.Body)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		chunk := OllamaResponse{}
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			return nil, err
+		}
+		if chunk.Error != "" {
+			return nil, fmt.Errorf("ollama: %s", chunk.Error)
+		}
+		if chunk.Model != "" {
+			result.Model = chunk.Model
+		}
+		for _, call := range chunk.Message.ToolCalls {
+			// Ollama no identifica las llamadas; se genera un id //
+			result.ToolCalls = append(result.ToolCalls, ToolCall{
+				Id:        toolspkg.GenerateUUID(),
+				Name:      call.Function.Name,
+				Arguments: string(call.Function.Arguments),
+			})
+		}
+		if chunk.Message.Content != "" {
+			sb.WriteString(chunk.Message.Content)
+			if req.Stream && onDelta != nil {
+				onDelta(sb.String())
+			}
+		}
+		if chunk.Done {
+			result.Usage = Usage{
+				Model:        result.Model,
+				InputTokens:  chunk.PromptEvalCount,
+				OutputTokens: chunk.EvalCount,
+			}
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, newNetworkError(err)
+	}
+	result.Text = sb.String()
+
+	return result, nil
+}
+
+// ollamaMessages traduce los turnos al formato de /api/chat
+func ollamaMessages(input []ChatMessage) []OllamaWire {
+	messages := make([]OllamaWire, 0, len(input))
+	for _, msg := range input {
+		wire := OllamaWire{Role: msg.Role, Content: msg.Content}
+		for _, call := range msg.ToolCalls {
+			var item struct {
+				Function struct {
+					Name      string          `json:"name"`
+					Arguments json.RawMessage `json:"arguments"`
+				} `json:"function"`
+			}
+			item.Function.Name = call.Name
+			item.Function.Arguments = json.RawMessage(call.Arguments)
+			if !json.Valid(item.Function.Arguments) {
+				item.Function.Arguments = json.RawMessage("{}")
+			}
+			wire.ToolCalls = append(wire.ToolCalls, item)
+		}
+		messages = append(messages, wire)
+	}
+	return messages
+}
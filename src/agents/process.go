@@ -0,0 +1,238 @@
+package agentspkg
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	eventpkg "main/src/event"
+	modelpkg "main/src/model"
+	toolspkg "main/src/tools"
+)
+
+// ProcessEvent es una línea JSON del protocolo stdio con agentes externos
+//
+//	entrada (stdin):  {"event":"message","message":{...}}
+//	salida (stdout):  {"event":"message"|"partial"|"system","message":{"text":"..."}}
+type ProcessEvent struct {
+	Event   string       `json:"event"`
+	Message MessageModel `json:"message"`
+}
+
+// ProcessAgent ejecuta un programa externo como agente; si el proceso
+// termina, Start devuelve error y el Manager aplica su reinicio/backoff
+type ProcessAgent struct {
+	Logger    *slog.Logger
+	Bus       *OptimizedBus
+	Command   *Command
+	AgentName string
+	Path      string
+	Args      []string
+	Dir       string
+	Env       []string
+
+	mu      sync.Mutex
+	origins map[string]MessageModel // mensajes enviados sin respuesta final, por id
+	replies map[string]string       // id asignado a la respuesta en curso de cada mensaje
+	last    string                  // último mensaje enviado; para salidas sin reply_to
+}
+
+func (a *ProcessAgent) Name() string { return a.AgentName }
+
+func (a *ProcessAgent) Start(ctx context.Context) error {
+	cmd := exec.CommandContext(ctx, a.Path, a.Args...)
+	cmd.Dir = a.Dir
+	cmd.Env = append(os.Environ(), a.Env...)
+	cmd.WaitDelay = 2 * time.Second
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+
+	ch, unsub, err := a.Bus.Subscribe(eventpkg.EvtMessage, 64)
+	if err != nil {
+		return err
+	}
+	defer unsub()
+
+	// cada proceso empieza sin peticiones pendientes //
+	a.mu.Lock()
+	a.origins = map[string]MessageModel{}
+	a.replies = map[string]string{}
+	a.last = ""
+	a.mu.Unlock()
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	a.Logger.Info("process agent started", "name", a.Name(), "pid", cmd.Process.Pid)
+
+	go a.forwardStderr(stderr)
+
+	exited := make(chan struct{})
+	go func() {
+		defer close(exited)
+		a.readStdout(stdout)
+	}()
+
+	// stdin se escribe en otra goroutine: un proceso que no lee no bloquea el bucle //
+	outbox := make(chan ProcessEvent, 64)
+	defer close(outbox)
+	go a.writeStdin(stdin, outbox)
+
+	for {
+		select {
+		case <-ctx.Done():
+			// CommandContext mata el proceso; se espera a que cierre stdout //
+			stdin.Close()
+			select {
+			case <-exited:
+			case <-time.After(cmd.WaitDelay):
+			}
+			cmd.Wait()
+			return ctx.Err()
+
+		case <-exited:
+			stdin.Close()
+			err := cmd.Wait()
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err == nil {
+				err = errors.New("exited")
+			}
+			return fmt.Errorf("process %s: %w", a.Path, err)
+
+		case evt, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			msg, _ := evt.Data.(MessageModel)
+			if msg.Type != modelpkg.TyText || msg.Source != modelpkg.ScHuman || !msg.IsAddressedTo(a.Name()) {
+				continue
+			}
+			if ok, _ := a.Command.IsCommand(msg.Text); ok {
+				continue
+			}
+
+			a.mu.Lock()
+			a.origins[msg.Id] = msg
+			a.last = msg.Id
+			a.mu.Unlock()
+
+			// si el proceso sale o se cancela, la vuelta siguiente lo atiende //
+			select {
+			case outbox <- ProcessEvent{Event: "message", Message: msg}:
+			case <-ctx.Done():
+			case <-exited:
+			}
+		}
+	}
+}
+
+// writeStdin envía al proceso los eventos de outbox hasta que se cierra; al
+// cerrar stdin una escritura bloqueada falla y la goroutine sigue vaciando
+func (a *ProcessAgent) writeStdin(stdin io.Writer, outbox <-chan ProcessEvent) {
+	encoder := json.NewEncoder(stdin)
+	for evt := range outbox {
+		if err := encoder.Encode(evt); err != nil {
+			a.Logger.Error("process agent write failed", "name", a.Name(), "error", err)
+		}
+	}
+}
+
+// readStdout publica en el bus cada evento JSON que escribe el proceso
+func (a *ProcessAgent) readStdout(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		var evt ProcessEvent
+		if err := json.Unmarshal(scanner.Bytes(), &evt); err != nil {
+			a.Logger.Warn("process agent invalid output", "name", a.Name(), "line", scanner.Text(), "error", err)
+			continue
+		}
+
+		message := a.correlate(evt)
+		message.WrittenBy = a.Name()
+		message.Type = modelpkg.TyText
+		message.Source = modelpkg.ScAssistant
+
+		switch evt.Event {
+		case "partial":
+			a.Bus.Publish(eventpkg.EvtPartial, message)
+		case "system":
+			message.Type = modelpkg.TySystem
+			message.Source = modelpkg.ScSystem
+			a.Bus.Publish(eventpkg.EvtMessage, message)
+		default:
+			a.Bus.Publish(eventpkg.EvtMessage, message)
+		}
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, os.ErrClosed) {
+		a.Logger.Error("process agent read failed", "name", a.Name(), "error", err)
+	}
+}
+
+// correlate completa hilo, reply_to e id de una salida del proceso. Los
+// parciales sin id y su mensaje final comparten el mismo id, así la vista
+// actualiza una sola entrada; la respuesta final cierra la petición
+func (a *ProcessAgent) correlate(evt ProcessEvent) MessageModel {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	message := evt.Message
+	if message.ReplyTo == "" {
+		message.ReplyTo = a.last
+	}
+	origin, pending := a.origins[message.ReplyTo]
+	if message.ThreadId == "" {
+		message.ThreadId = origin.ThreadId
+	}
+
+	// los avisos de sistema son mensajes aparte //
+	if evt.Event == "system" || !pending {
+		if message.Id == "" {
+			message.Id = toolspkg.GenerateUUID()
+		}
+		return message
+	}
+
+	if message.Id == "" {
+		message.Id = a.replies[message.ReplyTo]
+	}
+	if message.Id == "" {
+		message.Id = toolspkg.GenerateUUID()
+	}
+	if evt.Event == "partial" {
+		a.replies[message.ReplyTo] = message.Id
+	} else {
+		delete(a.replies, message.ReplyTo)
+		delete(a.origins, message.ReplyTo)
+	}
+	return message
+}
+
+// forwardStderr envía al logger cada línea de stderr del proceso
+func (a *ProcessAgent) forwardStderr(stderr io.Reader) {
+	scanner := bufio.NewScanner(stderr)
+	for scanner.Scan() {
+		a.Logger.Warn("process agent stderr", "name", a.Name(), "line", scanner.Text())
+	}
+}
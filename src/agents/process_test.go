@@ -0,0 +1,171 @@
+package agentspkg
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	buspkg "main/src/bus"
+	eventpkg "main/src/event"
+	managerpkg "main/src/manager"
+	modelpkg "main/src/model"
+)
+
+// el hijo espera dos mensajes y responde primero al segundo, en streaming
+const childScript = `
+import json, sys
+pending = []
+for line in sys.stdin:
+    pending.append(json.loads(line)["message"])
+    if len(pending) < 2:
+        continue
+    for message in reversed(pending):
+        for text in ("re: ", "re: " + message["text"]):
+            print(json.dumps({"event": "partial", "message": {"text": text, "reply_to": message["id"]}}), flush=True)
+        print(json.dumps({"event": "message", "message": {"text": "re: " + message["text"], "reply_to": message["id"]}}), flush=True)
+    pending = []
+`
+
+// el hijo responde al primer mensaje y se cae
+const crashScript = `
+import json, sys
+message = json.loads(sys.stdin.readline())["message"]
+print(json.dumps({"event": "message", "message": {"text": "re: " + message["text"]}}), flush=True)
+sys.exit(1)
+`
+
+// childAgent escribe el script en un directorio temporal; sin python3 se salta el test
+func childAgent(t *testing.T, source string) (string, string) {
+	t.Helper()
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 not available")
+	}
+	script := filepath.Join(t.TempDir(), "child.py")
+	if err := os.WriteFile(script, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return python, script
+}
+
+func TestProcessAgentCorrelatesReplies(t *testing.T) {
+	python, script := childAgent(t, childScript)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	bus := buspkg.NewMemoryBus(logger)
+	partials, unsubPartials, _ := bus.Subscribe(eventpkg.EvtPartial, 64)
+	defer unsubPartials()
+	messages, unsubMessages, _ := bus.Subscribe(eventpkg.EvtMessage, 64)
+	defer unsubMessages()
+
+	agent := &ProcessAgent{Logger: logger, Bus: bus, AgentName: "child", Path: python, Args: []string{script}}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go agent.Start(ctx)
+	time.Sleep(200 * time.Millisecond)
+
+	inbound := []MessageModel{
+		{Id: "m1", ThreadId: "thread-a", Type: modelpkg.TyText, Source: modelpkg.ScHuman, Text: "uno"},
+		{Id: "m2", ThreadId: "thread-b", Type: modelpkg.TyText, Source: modelpkg.ScHuman, Text: "dos"},
+	}
+	for _, msg := range inbound {
+		bus.Publish(eventpkg.EvtMessage, msg)
+	}
+
+	partialIds := map[string]map[string]bool{}
+	recordPartial := func(msg MessageModel) {
+		if partialIds[msg.ReplyTo] == nil {
+			partialIds[msg.ReplyTo] = map[string]bool{}
+		}
+		partialIds[msg.ReplyTo][msg.Id] = true
+	}
+	finals := map[string]MessageModel{}
+	timeout := time.After(5 * time.Second)
+	for len(finals) < 2 {
+		select {
+		case evt := <-partials:
+			recordPartial(evt.Data.(MessageModel))
+		case evt := <-messages:
+			msg := evt.Data.(MessageModel)
+			if msg.Source == modelpkg.ScAssistant {
+				finals[msg.ReplyTo] = msg
+			}
+		case <-timeout:
+			t.Fatalf("timeout, finals = %+v", finals)
+		}
+	}
+
+	// los parciales llegan por otro canal: se recogen los pendientes //
+	for drained := false; !drained; {
+		select {
+		case evt := <-partials:
+			recordPartial(evt.Data.(MessageModel))
+		case <-time.After(100 * time.Millisecond):
+			drained = true
+		}
+	}
+
+	for _, msg := range inbound {
+		final := finals[msg.Id]
+		if final.ThreadId != msg.ThreadId || final.Text != "re: "+msg.Text {
+			t.Errorf("reply to %s = %+v, want thread %s", msg.Id, final, msg.ThreadId)
+		}
+		ids := partialIds[msg.Id]
+		if len(ids) != 1 || !ids[final.Id] {
+			t.Errorf("reply to %s: partial ids %v, want only the final id %s", msg.Id, ids, final.Id)
+		}
+	}
+}
+
+func TestProcessAgentRestartsAfterCrash(t *testing.T) {
+	python, script := childAgent(t, crashScript)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	bus := buspkg.NewMemoryBus(logger)
+	messages, unsub, _ := bus.Subscribe(eventpkg.EvtMessage, 64)
+	defer unsub()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mgr := managerpkg.NewManager(ctx, logger)
+	agent := &ProcessAgent{Logger: logger, Bus: bus, AgentName: "child", Path: python, Args: []string{script}}
+	mgr.RegisterWithOptions(agent, managerpkg.Options{AutoRestart: true, MinBackoff: 10 * time.Millisecond})
+	if err := mgr.StartAgent("child"); err != nil {
+		t.Fatalf("StartAgent: %v", err)
+	}
+
+	// cada proceso contesta un mensaje y cae: la segunda respuesta es de otro proceso //
+	for idx, text := range []string{"uno", "dos"} {
+		reply := ""
+		timeout := time.After(5 * time.Second)
+		tick := time.NewTicker(100 * time.Millisecond)
+		for seq := 0; reply == ""; {
+			select {
+			case <-tick.C:
+				// se reenvía hasta que haya un proceso escuchando //
+				seq++
+				bus.Publish(eventpkg.EvtMessage, MessageModel{
+					Id: fmt.Sprintf("%s-%d", text, seq), Type: modelpkg.TyText, Source: modelpkg.ScHuman, Text: text,
+				})
+			case evt := <-messages:
+				if msg := evt.Data.(MessageModel); msg.Source == modelpkg.ScAssistant && msg.Text == "re: "+text {
+					reply = msg.Text
+				}
+			case <-timeout:
+				t.Fatalf("message %d: no reply to %q", idx, text)
+			}
+		}
+		tick.Stop()
+	}
+
+	status := mgr.ListAgents()[0]
+	if status.Restarts < 1 || status.LastErr == nil {
+		t.Errorf("status = %+v, want a restart after the crash", status)
+	}
+}
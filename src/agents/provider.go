@@ -0,0 +1,173 @@
+package agentspkg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	configpkg "main/src/config"
+	modelpkg "main/src/model"
+)
+
+type Config = configpkg.Config
+type ProviderConfig = configpkg.ProviderConfig
+type Usage = modelpkg.UsageModel
+
+// ChatMessage es un turno de la conversación enviado al proveedor
+type ChatMessage struct {
+	Role       string     `json:"role"` // "system" | "user" | "assistant" | "tool"
+	Content    string     `json:"content"`
+	ToolCalls  []ToolCall `json:"-"` // llamadas pedidas por el modelo (role "assistant")
+	ToolCallId string     `json:"-"` // llamada a la que responde (role "tool")
+}
+
+// ToolCall es una llamada a herramienta pedida por el modelo
+type ToolCall struct {
+	Id        string
+	Name      string
+	Arguments string // JSON
+}
+
+// ToolSpec describe una herramienta que se anuncia al modelo
+type ToolSpec struct {
+	Name        string
+	Description string
+	Parameters  map[string]any // JSON Schema
+}
+
+// ProviderRequest es la petición común a todos los proveedores
+type ProviderRequest struct {
+	Model        string
+	Instructions string
+	Conversation string // estado remoto del hilo; vacío si se envía el historial
+	Input        []ChatMessage
+	History      []ChatMessage // turnos locales que cubre Conversation; solo para la caché
+	Tools        []ToolSpec
+	Temperature  *float64
+	TopP         *float64
+	MaxOutput    *int   // tokens máximos de la respuesta
+	Reasoning    string // esfuerzo de razonamiento: "minimal" | "low" | "medium" | "high"
+	Stream       bool
+}
+
+// ProviderResponse es la respuesta común de todos los proveedores
+type ProviderResponse struct {
+	Id           string
+	Model        string
+	Conversation string // estado remoto a guardar; vacío si el proveedor no lo soporta
+	Text         string
+	ToolCalls    []ToolCall
+	Usage        Usage
+	Cached       bool // servida desde la caché; Usage es el de la petición original
+}
+
+// Provider abstrae la API de un modelo de lenguaje
+type Provider interface {
+	Name() string
+	// Complete envía la petición; onDelta recibe el texto acumulado en streaming
+	Complete(ctx context.Context, req ProviderRequest, onDelta func(text string)) (*ProviderResponse, error)
+}
+
+// NewProvider construye el proveedor según su `kind` en config
+func NewProvider(conf ProviderConfig, client *http.Client) (Provider, error) {
+	if client == nil {
+		client = &http.Client{}
+	}
+	url := strings.TrimRight(os.ExpandEnv(conf.Url), "/")
+	key := os.ExpandEnv(conf.Key)
+
+	// record/replay: el transporte graba o sustituye a la red //
+	if conf.Mode != "" {
+		transport, err := NewFixtureTransport(conf.Mode, os.ExpandEnv(conf.Fixtures), client.Transport)
+		if err != nil {
+			return nil, err
+		}
+		client = &http.Client{Transport: transport, Timeout: client.Timeout}
+		if url == "" && conf.Mode == FixtureReplay {
+			url = "http://replay.invalid"
+		}
+	}
+
+	switch conf.Kind {
+	case "responses", "":
+		return &ResponsesProvider{Url: url, Key: key, Client: client}, nil
+	case "chat":
+		return &ChatProvider{Url: url, Key: key, Client: client}, nil
+	case "ollama":
+		return &OllamaProvider{Url: url, Client: client}, nil
+	default:
+		return nil, fmt.Errorf("provider kind %q not supported", conf.Kind)
+	}
+}
+
+// ProviderFor construye el proveedor y el modelo de la declaración de un
+// agente; sirve igual para los de config.yaml y los de `/agent add`
+func ProviderFor(conf *Config, def AgentConfig) (Provider, string, error) {
+	providerConf, ok := conf.Provider(def.Provider)
+	if !ok {
+		return nil, "", fmt.Errorf("provider %q not configured", def.Provider)
+	}
+
+	provider, err := NewProvider(providerConf, nil)
+	if err != nil {
+		return nil, "", err
+	}
+
+	model := def.Model
+	if model == "" {
+		model = providerConf.Model
+	}
+
+	return provider, os.ExpandEnv(model), nil
+}
+
+// postJSON envía el cuerpo como JSON y valida el status de la respuesta
+func postJSON(
+	ctx context.Context,
+	client *http.Client,
+	url string,
+	key string,
+	payload any,
+	stream bool,
+) (*http.Response, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+	if stream {
+		req.Header.Add("Accept", "text/event-stream")
+	}
+	if key != "" {
+		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", key))
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, newNetworkError(err)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		defer res.Body.Close()
+		detail, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return nil, newStatusError(res, strings.TrimSpace(string(detail)))
+	}
+
+	return res, nil
+}
+
+// isEventStream indica si la respuesta llega como SSE
+func isEventStream(res *http.Response) bool {
+	return strings.HasPrefix(res.Header.Get("Content-Type"), "text/event-stream")
+}
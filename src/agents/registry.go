@@ -0,0 +1,208 @@
+package agentspkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	managerpkg "main/src/manager"
+	modelpkg "main/src/model"
+)
+
+type AgentKindModel = modelpkg.AgentKindModel
+type AgentRecordModel = modelpkg.AgentRecordModel
+
+// Kinds son los tipos de agente que sabe construir Build
+var Kinds = []AgentKindModel{
+	{
+		Name:        "llm",
+		Description: "Modelo de un proveedor declarado en config.yaml",
+		Params:      []string{"provider", "model", "instructions", "temperature", "context", "tools", "max_tool_rounds", "compact_at", "compact_keep"},
+	},
+	{
+		Name:        "echo",
+		Description: "Repite los mensajes que recibe; útil para pruebas",
+	},
+	{
+		Name:        "process",
+		Description: "Programa externo que habla JSON por líneas en stdin/stdout",
+		Params:      []string{"command", "args", "dir", "env"},
+	},
+}
+
+// CommonParams son las claves de reinicio y supervisión que aceptan todos los tipos
+var CommonParams = []string{
+	"auto_restart", "min_backoff", "max_backoff", "group", "strategy",
+	"max_restarts", "restart_window", "stable_after", "stop_timeout",
+}
+
+// listParams se escriben separadas por comas: tools=read_file,list_threads
+var listParams = []string{"tools", "args", "env"}
+
+// los nombres se usan en @menciones
+var agentName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// Definition arma la declaración de un agente a partir de parámetros clave=valor,
+// con la misma forma y tipos que en config.yaml
+func Definition(kind string, name string, params map[string]string) (AgentConfig, error) {
+	var def AgentConfig
+	if !agentName.MatchString(name) {
+		return def, fmt.Errorf("invalid agent name %q", name)
+	}
+	idx := slices.IndexFunc(Kinds, func(item AgentKindModel) bool { return item.Name == kind })
+	if idx < 0 {
+		return def, fmt.Errorf("agent kind %q not supported", kind)
+	}
+
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		if !slices.Contains(Kinds[idx].Params, key) && !slices.Contains(CommonParams, key) {
+			return def, fmt.Errorf("parameter %q not supported by kind %s", key, kind)
+		}
+		value := &yaml.Node{Kind: yaml.ScalarNode, Value: params[key]}
+		if slices.Contains(listParams, key) {
+			value = &yaml.Node{Kind: yaml.SequenceNode}
+			for _, item := range strings.Split(params[key], ",") {
+				value.Content = append(value.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: item, Style: yaml.DoubleQuotedStyle})
+			}
+		} else if key == "instructions" || key == "model" || key == "provider" {
+			value.Style = yaml.DoubleQuotedStyle
+		}
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, value)
+	}
+	if err := node.Decode(&def); err != nil {
+		return def, err
+	}
+
+	def.Name = name
+	def.Kind = kind
+	return def, nil
+}
+
+// restore lee de la base de datos qué agentes están deshabilitados y las
+// declaraciones creadas con `/agent add`; solo la primera vez
+func (l *Loader) restore() []AgentConfig {
+	if l.restored || l.deps.Db == nil {
+		return nil
+	}
+	l.restored = true
+
+	records, err := l.deps.Db.ListAgentRecords()
+	if err != nil {
+		return nil
+	}
+	var defs []AgentConfig
+	for _, record := range records {
+		if !record.Enabled {
+			l.disabled[record.Name] = true
+		}
+		if record.Config == "" {
+			continue
+		}
+		var def AgentConfig
+		if err := json.Unmarshal([]byte(record.Config), &def); err != nil {
+			l.deps.Logger.Error("Error restoring agent", "name", record.Name, "error", err)
+			continue
+		}
+		defs = append(defs, def)
+	}
+	return defs
+}
+
+// register construye el agente y lo registra con su política de reinicio
+// y su habilitación guardada
+func (l *Loader) register(conf *Config, def AgentConfig) error {
+	agent, err := Build(conf, def, l.deps)
+	if err != nil {
+		return err
+	}
+	opts, err := BuildOptions(def)
+	if err != nil {
+		return err
+	}
+	opts.Disabled = l.disabled[def.Name]
+	l.mgr.RegisterWithOptions(agent, opts)
+	return nil
+}
+
+// Kinds devuelve los tipos de agente disponibles para `/agent add`
+func (l *Loader) Kinds() []AgentKindModel {
+	return Kinds
+}
+
+// Add crea un agente en caliente y guarda su declaración para
+// restaurarlo en los próximos arranques
+func (l *Loader) Add(kind string, name string, params map[string]string) error {
+	def, err := Definition(kind, name, params)
+	if err != nil {
+		return err
+	}
+	if l.mgr.Has(name) {
+		return fmt.Errorf("agent %s already exists", name)
+	}
+
+	data, err := json.Marshal(def)
+	if err != nil {
+		return err
+	}
+	if err := l.register(l.config, def); err != nil {
+		return err
+	}
+	if l.deps.Db != nil {
+		if err := l.deps.Db.SaveAgentRecord(AgentRecordModel{Name: name, Config: string(data), Enabled: !l.disabled[name]}); err != nil {
+			l.mgr.Unregister(name)
+			return err
+		}
+	}
+	l.runtime[name] = def
+	return nil
+}
+
+// Remove da de baja un agente creado con `/agent add`; los de config.yaml
+// se quitan del archivo o se deshabilitan
+func (l *Loader) Remove(name string) error {
+	if !l.mgr.Has(name) {
+		return &managerpkg.UnknownAgentError{Name: name}
+	}
+	if _, declared := l.applied[name]; declared {
+		return fmt.Errorf("agent %s is declared in config.yaml; remove it there or use /agent disable", name)
+	}
+
+	if err := l.mgr.Unregister(name); err != nil {
+		return err
+	}
+	delete(l.runtime, name)
+	delete(l.disabled, name)
+	if l.deps.Db != nil {
+		return l.deps.Db.DeleteAgentRecord(name)
+	}
+	return nil
+}
+
+// SetEnabled habilita o deshabilita un agente y lo recuerda entre arranques;
+// deshabilitarlo lo detiene
+func (l *Loader) SetEnabled(name string, enabled bool) error {
+	if !l.mgr.Has(name) {
+		return &managerpkg.UnknownAgentError{Name: name}
+	}
+	if l.deps.Db != nil {
+		if err := l.deps.Db.SetAgentEnabled(name, enabled); err != nil {
+			return err
+		}
+	}
+	if enabled {
+		delete(l.disabled, name)
+	} else {
+		l.disabled[name] = true
+	}
+	return l.mgr.SetEnabled(name, enabled)
+}
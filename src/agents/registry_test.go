@@ -0,0 +1,78 @@
+package agentspkg
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"testing"
+
+	buspkg "main/src/bus"
+	databasepkg "main/src/database"
+	managerpkg "main/src/manager"
+)
+
+// testDatabase abre una base de datos nueva en un directorio temporal
+func testDatabase(t *testing.T, logger *slog.Logger) *Database {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	db, err := databasepkg.NewDatabase(logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Migration(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestLoaderAddAndRestoreLLMAgent(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	deps := Deps{Logger: logger, Bus: buspkg.NewMemoryBus(logger), Db: testDatabase(t, logger)}
+
+	conf := &Config{}
+	conf.Config.Providers = []ProviderConfig{{Name: "openai", Kind: "responses", Url: "http://localhost:0", Model: "base-model"}}
+
+	mgr := managerpkg.NewManager(context.Background(), logger)
+	loader := NewLoader(conf, "", mgr, deps)
+	if _, _, _, err := loader.Apply(conf); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	err := loader.Add("llm", "writer", map[string]string{"provider": "openai", "model": "writer-model", "instructions": "sé breve"})
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if !mgr.Has("writer") {
+		t.Fatalf("writer not registered")
+	}
+
+	// siguiente arranque: el agente sale de la base de datos //
+	restoredMgr := managerpkg.NewManager(context.Background(), logger)
+	restored := NewLoader(conf, "", restoredMgr, deps)
+	added, _, _, err := restored.Apply(conf)
+	if err != nil {
+		t.Fatalf("Apply restore: %v", err)
+	}
+	if len(added) != 1 || added[0] != "writer" || !restoredMgr.Has("writer") {
+		t.Fatalf("added = %v, want writer restored", added)
+	}
+
+	def := restored.runtime["writer"]
+	agent, err := Build(conf, def, deps)
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	llm := agent.(*AAgent)
+	if llm.Model != "writer-model" || llm.Instructions != "sé breve" {
+		t.Errorf("agent = model %q instructions %q", llm.Model, llm.Instructions)
+	}
+}
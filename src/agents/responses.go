@@ -0,0 +1,305 @@
+package agentspkg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ResponsesProvider usa la API /responses (OpenAI Responses)
+type ResponsesProvider struct {
+	Url    string
+	Key    string
+	Client *http.Client
+}
+
+type Response struct {
+	ID           string `json:"id"`
+	Object       string `json:"object"`
+	CreatedAt    int    `json:"created_at"`
+	Status       string `json:"status"`
+	Background   bool   `json:"background"`
+	Conversation struct {
+		ID string `json:"id"`
+	} `json:"conversation"`
+	Error              any              `json:"error"`
+	IncompleteDetails  any              `json:"incomplete_details"`
+	Instructions       any              `json:"instructions"`
+	MaxOutputTokens    any              `json:"max_output_tokens"`
+	MaxToolCalls       any              `json:"max_tool_calls"`
+	Model              string           `json:"model"`
+	Output             []ResponseOutput `json:"output"`
+	ParallelToolCalls  bool             `json:"parallel_tool_calls"`
+	PreviousResponseID any              `json:"previous_response_id"`
+	PromptCacheKey     any              `json:"prompt_cache_key"`
+	Reasoning          struct {
+		Effort  string `json:"effort"`
+		Summary any    `json:"summary"`
+	} `json:"reasoning"`
+	SafetyIdentifier any     `json:"safety_identifier"`
+	ServiceTier      string  `json:"service_tier"`
+	Store            bool    `json:"store"`
+	Temperature      float64 `json:"temperature"`
+	Text             struct {
+		Format struct {
+			Type string `json:"type"`
+		} `json:"format"`
+		Verbosity string `json:"verbosity"`
+	} `json:"text"`
+	ToolChoice  string  `json:"tool_choice"`
+	Tools       []any   `json:"tools"`
+	TopLogprobs int     `json:"top_logprobs"`
+	TopP        float64 `json:"top_p"`
+	Truncation  string  `json:"truncation"`
+	Usage       struct {
+		InputTokens        int `json:"input_tokens"`
+		InputTokensDetails struct {
+			CachedTokens int `json:"cached_tokens"`
+		} `json:"input_tokens_details"`
+		OutputTokens        int `json:"output_tokens"`
+		OutputTokensDetails struct {
+			ReasoningTokens int `json:"reasoning_tokens"`
+		} `json:"output_tokens_details"`
+		TotalTokens int `json:"total_tokens"`
+	} `json:"usage"`
+	User     any `json:"user"`
+	Metadata struct {
+	} `json:"metadata"`
+}
+
+type ResponseOutput struct {
+	ID        string            `json:"id"`
+	Type      string            `json:"type"`
+	Summary   []any             `json:"summary,omitempty"`
+	Status    string            `json:"status,omitempty"`
+	Content   []ResponseContent `json:"content,omitempty"`
+	Role      string            `json:"role,omitempty"`
+	CallID    string            `json:"call_id,omitempty"`   // type "function_call"
+	Name      string            `json:"name,omitempty"`      // type "function_call"
+	Arguments string            `json:"arguments,omitempty"` // type "function_call"
+}
+
+type ResponseContent struct {
+	Type        string `json:"type"`
+	Annotations []any  `json:"annotations"`
+	Logprobs    []any  `json:"logprobs"`
+	Text        string `json:"text"`
+}
+
+// OutputText devuelve el texto del último mensaje de salida
+func (r *Response) OutputText() string {
+	for i := len(r.Output) - 1; i >= 0; i-- {
+		output := r.Output[i]
+		if output.Type != "" && output.Type != "message" {
+			continue
+		}
+		var sb strings.Builder
+		for _, content := range output.Content {
+			sb.WriteString(content.Text)
+		}
+		if sb.Len() > 0 {
+			return sb.String()
+		}
+	}
+	return ""
+}
+
+// ToolCalls devuelve las llamadas a funciones pedidas en la salida
+func (r *Response) ToolCalls() []ToolCall {
+	var calls []ToolCall
+	for _, output := range r.Output {
+		if output.Type == "function_call" {
+			calls = append(calls, ToolCall{
+				Id:        output.CallID,
+				Name:      output.Name,
+				Arguments: output.Arguments,
+			})
+		}
+	}
+	return calls
+}
+
+// SetOutputText añade un mensaje de salida con el texto dado
+func (r *Response) SetOutputText(text string) {
+	r.Output = append(r.Output, ResponseOutput{
+		Type:    "message",
+		Role:    "assistant",
+		Content: []ResponseContent{{Type: "output_text", Text: text}},
+	})
+}
+
+type Payload struct {
+	Model              string          `json:"model"`
+	PreviousResponseID string          `json:"previous_response_id,omitempty"`
+	Instructions       string          `json:"instructions,omitempty"`
+	Input              []any           `json:"input"`
+	Tools              []ResponsesTool `json:"tools,omitempty"`
+	Temperature        *float64        `json:"temperature,omitempty"`
+	TopP               *float64        `json:"top_p,omitempty"`
+	MaxOutputTokens    *int            `json:"max_output_tokens,omitempty"`
+	Reasoning          *struct {
+		Effort string `json:"effort"`
+	} `json:"reasoning,omitempty"`
+	Stream bool `json:"stream,omitempty"`
+}
+
+type ResponsesTool struct {
+	Type        string         `json:"type"`
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	Parameters  map[string]any `json:"parameters"`
+}
+
+// responsesInput traduce los turnos a items de /responses: mensajes,
+// llamadas a funciones y sus resultados
+func responsesInput(input []ChatMessage) []any {
+	items := []any{}
+	for _, msg := range input {
+		switch {
+		case msg.Role == "tool":
+			items = append(items, map[string]any{
+				"type":    "function_call_output",
+				"call_id": msg.ToolCallId,
+				"output":  msg.Content,
+			})
+		case len(msg.ToolCalls) > 0:
+			if msg.Content != "" {
+				items = append(items, ChatMessage{Role: msg.Role, Content: msg.Content})
+			}
+			for _, call := range msg.ToolCalls {
+				items = append(items, map[string]any{
+					"type":      "function_call",
+					"call_id":   call.Id,
+					"name":      call.Name,
+					"arguments": call.Arguments,
+				})
+			}
+		default:
+			items = append(items, msg)
+		}
+	}
+	return items
+}
+
+// StreamEvent representa un evento SSE de la API /responses
+type StreamEvent struct {
+	Type     string    `json:"type"`
+	Delta    string    `json:"delta"`
+	Response *Response `json:"response"`
+	Message  string    `json:"message"`
+}
+
+func (p *ResponsesProvider) Name() string { return "responses" }
+
+func (p *ResponsesProvider) Complete(
+	ctx context.Context,
+	req ProviderRequest,
+	onDelta func(text string),
+) (*ProviderResponse, error) {
+	payload := Payload{
+		Model:              req.Model,
+		PreviousResponseID: req.Conversation,
+		Instructions:       req.Instructions,
+		Input:              responsesInput(req.Input),
+		Temperature:        req.Temperature,
+		TopP:               req.TopP,
+		MaxOutputTokens:    req.MaxOutput,
+		Stream:             req.Stream,
+	}
+	if req.Reasoning != "" {
+		payload.Reasoning = &struct {
+			Effort string `json:"effort"`
+		}{Effort: req.Reasoning}
+	}
+	for _, tool := range req.Tools {
+		payload.Tools = append(payload.Tools, ResponsesTool{
+			Type:        "function",
+			Name:        tool.Name,
+			Description: tool.Description,
+			Parameters:  tool.Parameters,
+		})
+	}
+
+	res, err := postJSON(ctx, p.Client, p.Url+"/responses", p.Key, payload, req.Stream)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	response := &Response{}
+	// La API responde en streaming (SSE) o con el JSON completo //
+	if isEventStream(res) {
+		response, err = ReadResponseStream(res.Body, onDelta)
+	} else {
+		err = json.NewDecoder(res.Body).Decode(response)
+	}
+	if err != nil {
+		return nil, newNetworkError(err)
+	}
+
+	// El id de la respuesta encadena el siguiente turno (previous_response_id) //
+	return &ProviderResponse{
+		Id:           response.ID,
+		Model:        response.Model,
+		Conversation: response.ID,
+		Text:         response.OutputText(),
+		ToolCalls:    response.ToolCalls(),
+		Usage: Usage{
+			Model:           response.Model,
+			InputTokens:     response.Usage.InputTokens,
+			CachedTokens:    response.Usage.InputTokensDetails.CachedTokens,
+			OutputTokens:    response.Usage.OutputTokens,
+			ReasoningTokens: response.Usage.OutputTokensDetails.ReasoningTokens,
+		},
+	}, nil
+}
+
+// ReadResponseStream consume los eventos de /responses acumulando los deltas
+// de texto; onDelta recibe el texto acumulado hasta el momento
+func ReadResponseStream(r io.Reader, onDelta func(text string)) (*Response, error) {
+	var sb strings.Builder
+	var final *Response
+
+	err := ReadSSE(r, func(event string, data string) error {
+		var evt StreamEvent
+		if err := json.Unmarshal([]byte(data), &evt); err != nil {
+			return err
+		}
+		if evt.Type == "" {
+			evt.Type = event
+		}
+
+		switch evt.Type {
+		case "response.output_text.delta":
+			sb.WriteString(evt.Delta)
+			if onDelta != nil {
+				onDelta(sb.String())
+			}
+		case "response.completed", "response.incomplete":
+			final = evt.Response
+		case "response.failed":
+			if evt.Response != nil && evt.Response.Error != nil {
+				return fmt.Errorf("response failed: %v", evt.Response.Error)
+			}
+			return fmt.Errorf("response failed")
+		case "error":
+			return fmt.Errorf("stream error: %s", evt.Message)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if final == nil {
+		final = &Response{}
+	}
+	// Si el evento final no trae el texto, usa el acumulado //
+	if final.OutputText() == "" && sb.Len() > 0 {
+		final.SetOutputText(sb.String())
+	}
+	return final, nil
+}
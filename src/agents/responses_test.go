@@ -0,0 +1,79 @@
+package agentspkg
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	buspkg "main/src/bus"
+)
+
+func testAgent(provider Provider, policy RetryPolicy) *AAgent {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	return &AAgent{
+		Logger:   logger,
+		Bus:      buspkg.NewMemoryBus(logger),
+		Provider: provider,
+		Retry:    policy,
+	}
+}
+
+func TestStubStreamedReply(t *testing.T) {
+	stub := NewStubServer(StubReply{Text: "hola desde el stub", Usage: Usage{InputTokens: 3, OutputTokens: 4}})
+	defer stub.Close()
+
+	var deltas []string
+	response, err := stub.Provider().Complete(context.Background(), ProviderRequest{
+		Model:  "stub-model",
+		Input:  []ChatMessage{{Role: "user", Content: "hola"}},
+		Stream: true,
+	}, func(text string) {
+		deltas = append(deltas, text)
+	})
+	if err != nil {
+		t.Fatalf("Complete: %v", err)
+	}
+
+	if response.Text != "hola desde el stub" {
+		t.Errorf("text = %q", response.Text)
+	}
+	if len(deltas) < 2 || deltas[len(deltas)-1] != "hola desde el stub" {
+		t.Errorf("deltas = %q, want accumulated word by word", deltas)
+	}
+	if response.Usage.InputTokens != 3 || response.Usage.OutputTokens != 4 {
+		t.Errorf("usage = %+v", response.Usage)
+	}
+	if requests := stub.Requests(); len(requests) != 1 || !requests[0].Stream {
+		t.Errorf("requests = %+v, want one streamed request", requests)
+	}
+}
+
+func TestStubRetriesTooManyRequests(t *testing.T) {
+	stub := NewStubServer(
+		StubReply{Status: 429, RetryAfter: "1"},
+		StubReply{Text: "ok"},
+	)
+	defer stub.Close()
+
+	agent := testAgent(stub.Provider(), RetryPolicy{
+		Attempts:      3,
+		MinBackoff:    time.Millisecond,
+		MaxBackoff:    time.Millisecond,
+		MaxRetryAfter: 20 * time.Millisecond,
+	})
+	response, err := agent.Request(context.Background(), ProviderRequest{
+		Model: "stub-model",
+		Input: []ChatMessage{{Role: "user", Content: "hola"}},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Request: %v", err)
+	}
+	if response.Text != "ok" {
+		t.Errorf("text = %q", response.Text)
+	}
+	if requests := len(stub.Requests()); requests != 2 {
+		t.Errorf("requests = %d, want 2 (429 + retry)", requests)
+	}
+}
@@ -0,0 +1,33 @@
+package agentspkg
+
+import (
+	indexpkg "main/src/index"
+)
+
+// Retrieve busca en el índice local los fragmentos relevantes para el mensaje
+// y los inserta como contexto justo antes del turno actual
+func (a *AAgent) Retrieve(msg MessageModel, input []ChatMessage) []ChatMessage {
+	if a.Index == nil || len(input) == 0 {
+		return input
+	}
+
+	chunks, err := a.Index.Search(msg.Text)
+	if err != nil {
+		a.Logger.Error("Failed to search index", "agent", a.Name(), "error", err)
+		return input
+	}
+	if len(chunks) == 0 {
+		return input
+	}
+
+	citations := make([]string, len(chunks))
+	for idx, chunk := range chunks {
+		citations[idx] = indexpkg.Citation(chunk)
+	}
+	a.Logger.Info("Retrieved index chunks", "agent", a.Name(), "thread", msg.ThreadId, "chunks", citations)
+
+	current := input[len(input)-1]
+	result := append([]ChatMessage{}, input[:len(input)-1]...)
+	result = append(result, ChatMessage{Role: "system", Content: indexpkg.Context(chunks)})
+	return append(result, current)
+}
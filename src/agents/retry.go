@@ -0,0 +1,67 @@
+package agentspkg
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+)
+
+// RetryPolicy define cuántas veces y con qué espera se reintenta una petición
+type RetryPolicy struct {
+	Attempts      int           // intentos totales, incluido el primero
+	MinBackoff    time.Duration // espera base del primer reintento
+	MaxBackoff    time.Duration // espera máxima entre intentos
+	MaxRetryAfter time.Duration // tope para el Retry-After del servidor
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	Attempts:      4,
+	MinBackoff:    500 * time.Millisecond,
+	MaxBackoff:    10 * time.Second,
+	MaxRetryAfter: 60 * time.Second,
+}
+
+// Backoff calcula la espera antes del reintento `attempt` (desde 1) con jitter
+func (p RetryPolicy) Backoff(attempt int, err error) time.Duration {
+	var perr *ProviderError
+	if errors.As(err, &perr) && perr.RetryAfter > 0 {
+		if p.MaxRetryAfter > 0 && perr.RetryAfter > p.MaxRetryAfter {
+			return p.MaxRetryAfter
+		}
+		return perr.RetryAfter
+	}
+
+	backoff := p.MinBackoff * time.Duration(1<<(attempt-1))
+	if backoff > p.MaxBackoff || backoff <= 0 {
+		backoff = p.MaxBackoff
+	}
+	// jitter: entre la mitad y el total de la espera //
+	half := backoff / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// Retry ejecuta fn hasta que tenga éxito, falle de forma permanente o se agoten los intentos
+func Retry(ctx context.Context, policy RetryPolicy, fn func(attempt int) error) error {
+	attempts := policy.Attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		err = fn(attempt)
+		if err == nil || !IsTransient(err) || attempt == attempts {
+			return err
+		}
+
+		timer := time.NewTimer(policy.Backoff(attempt, err))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return err
+}
@@ -0,0 +1,130 @@
+package agentspkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestProviderErrorTransient(t *testing.T) {
+	cases := []struct {
+		status    int
+		transient bool
+	}{
+		{0, true}, // red
+		{http.StatusBadRequest, false},
+		{http.StatusUnauthorized, false},
+		{http.StatusNotFound, false},
+		{http.StatusRequestTimeout, true},
+		{http.StatusTooManyRequests, true},
+		{http.StatusInternalServerError, true},
+		{http.StatusBadGateway, true},
+		{http.StatusServiceUnavailable, true},
+	}
+	for _, tc := range cases {
+		err := fmt.Errorf("wrapped: %w", &ProviderError{StatusCode: tc.status})
+		if got := IsTransient(err); got != tc.transient {
+			t.Errorf("status %d: transient = %v, want %v", tc.status, got, tc.transient)
+		}
+	}
+}
+
+func TestNetworkErrorTransient(t *testing.T) {
+	cases := []struct {
+		name      string
+		err       error
+		transient bool
+	}{
+		{"dial", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, true},
+		{"unexpected eof", io.ErrUnexpectedEOF, true},
+		{"canceled", context.Canceled, false},
+		{"deadline", context.DeadlineExceeded, false},
+		{"other", errors.New("invalid json"), false},
+	}
+	for _, tc := range cases {
+		if got := IsTransient(newNetworkError(tc.err)); got != tc.transient {
+			t.Errorf("%s: transient = %v, want %v", tc.name, got, tc.transient)
+		}
+	}
+}
+
+// statusServer responde con los status de la lista y 200 cuando se acaban
+func statusServer(t *testing.T, retryAfter string, statuses ...int) (*ResponsesProvider, *atomic.Int32) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call := int(calls.Add(1))
+		if call <= len(statuses) {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			w.WriteHeader(statuses[call-1])
+			fmt.Fprint(w, `{"error":{"message":"fail"}}`)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id":"resp_1","status":"completed","output":[{"type":"message","content":[{"type":"output_text","text":"ok"}]}]}`)
+	}))
+	t.Cleanup(server.Close)
+	return &ResponsesProvider{Url: server.URL, Key: "test", Client: server.Client()}, &calls
+}
+
+func retryComplete(provider Provider, policy RetryPolicy) (*ProviderResponse, error) {
+	var response *ProviderResponse
+	err := Retry(context.Background(), policy, func(attempt int) error {
+		var err error
+		response, err = provider.Complete(context.Background(), ProviderRequest{Model: "test"}, nil)
+		return err
+	})
+	return response, err
+}
+
+var fastPolicy = RetryPolicy{Attempts: 3, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}
+
+func TestRetryHonorsRetryAfter(t *testing.T) {
+	provider, calls := statusServer(t, "1", http.StatusTooManyRequests)
+
+	start := time.Now()
+	response, err := retryComplete(provider, fastPolicy)
+	if err != nil {
+		t.Fatalf("Retry: %v", err)
+	}
+	if response.Text != "ok" || calls.Load() != 2 {
+		t.Errorf("text = %q, calls = %d", response.Text, calls.Load())
+	}
+	if elapsed := time.Since(start); elapsed < 900*time.Millisecond {
+		t.Errorf("retried after %s, want the 1s of Retry-After", elapsed)
+	}
+}
+
+func TestRetryStopsAtMaxAttempts(t *testing.T) {
+	provider, calls := statusServer(t, "", http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusServiceUnavailable)
+
+	_, err := retryComplete(provider, fastPolicy)
+	var perr *ProviderError
+	if !errors.As(err, &perr) || perr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("err = %v, want 503", err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("calls = %d, want 3 attempts", calls.Load())
+	}
+}
+
+func TestRetryDoesNotRetryClientErrors(t *testing.T) {
+	provider, calls := statusServer(t, "", http.StatusBadRequest)
+
+	_, err := retryComplete(provider, fastPolicy)
+	var perr *ProviderError
+	if !errors.As(err, &perr) || perr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("err = %v, want 400", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("calls = %d, want no retries", calls.Load())
+	}
+}
@@ -0,0 +1,60 @@
+package agentspkg
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// ReadSSE lee un flujo text/event-stream y llama a fn por cada bloque `data:`
+func ReadSSE(r io.Reader, fn func(event string, data string) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+
+	event := ""
+	var data []string
+
+	flush := func() error {
+		if len(data) == 0 {
+			event = ""
+			return nil
+		}
+		payload := strings.Join(data, "\n")
+		name := event
+		event = ""
+		data = data[:0]
+		if payload == "[DONE]" {
+			return io.EOF
+		}
+		return fn(name, payload)
+	}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		switch {
+		case line == "":
+			// fin de bloque //
+			if err := flush(); err != nil {
+				if err == io.EOF {
+					return nil
+				}
+				return err
+			}
+		case strings.HasPrefix(line, ":"):
+			// comentario / keep-alive //
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	if err := flush(); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package agentspkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StubReply es la respuesta programada para una petición al StubServer
+type StubReply struct {
+	Status     int           // 0 = 200; otro valor responde ese error (429, 500...)
+	RetryAfter string        // cabecera Retry-After de los errores
+	Text       string        // texto de salida; en streaming se envía palabra a palabra
+	ToolCalls  []ToolCall    // llamadas a funciones que pide el "modelo"
+	Usage      Usage         // tokens que se informan
+	Delay      time.Duration // espera antes de responder (cancelaciones, timeouts)
+}
+
+// StubServer es un servidor HTTP local que habla el esquema de /responses;
+// responde con las StubReply en cola y, cuando se acaban, repite el último
+// texto de entrada ("echo: ...")
+type StubServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	replies  []StubReply
+	requests []Payload
+}
+
+func NewStubServer(replies ...StubReply) *StubServer {
+	s := &StubServer{replies: replies}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Enqueue añade respuestas a la cola
+func (s *StubServer) Enqueue(replies ...StubReply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.replies = append(s.replies, replies...)
+}
+
+// Requests devuelve las peticiones recibidas, en orden
+func (s *StubServer) Requests() []Payload {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Payload{}, s.requests...)
+}
+
+// Provider devuelve un ResponsesProvider apuntando al servidor
+func (s *StubServer) Provider() *ResponsesProvider {
+	return &ResponsesProvider{Url: s.URL, Key: "stub", Client: s.Client()}
+}
+
+func (s *StubServer) handle(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != "/responses" {
+		http.NotFound(w, r)
+		return
+	}
+	var payload Payload
+	if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+		http.Error(w, `{"error":{"message":"invalid json"}}`, http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, payload)
+	id := "resp_stub_" + strconv.Itoa(len(s.requests))
+	reply := StubReply{Text: "echo: " + lastInputText(payload.Input)}
+	if len(s.replies) > 0 {
+		reply = s.replies[0]
+		s.replies = s.replies[1:]
+	}
+	s.mu.Unlock()
+
+	if reply.Delay > 0 {
+		select {
+		case <-time.After(reply.Delay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if reply.Status != 0 && reply.Status != http.StatusOK {
+		if reply.RetryAfter != "" {
+			w.Header().Set("Retry-After", reply.RetryAfter)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(reply.Status)
+		fmt.Fprintf(w, `{"error":{"message":%q}}`, http.StatusText(reply.Status))
+		return
+	}
+
+	response := stubResponse(id, payload, reply)
+	if !payload.Stream {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	// Streaming: created, un delta por palabra y completed con la respuesta entera //
+	w.Header().Set("Content-Type", "text/event-stream")
+	flusher, _ := w.(http.Flusher)
+	send := func(evt StreamEvent) {
+		data, _ := json.Marshal(evt)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", evt.Type, data)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	send(StreamEvent{Type: "response.created", Response: &Response{ID: id, Model: payload.Model, Status: "in_progress"}})
+	for _, word := range strings.SplitAfter(reply.Text, " ") {
+		if word != "" {
+			send(StreamEvent{Type: "response.output_text.delta", Delta: word})
+		}
+	}
+	send(StreamEvent{Type: "response.completed", Response: response})
+}
+
+// stubResponse arma el Response completo de una StubReply
+func stubResponse(id string, payload Payload, reply StubReply) *Response {
+	response := &Response{
+		ID:        id,
+		Object:    "response",
+		CreatedAt: int(time.Now().Unix()),
+		Status:    "completed",
+		Model:     payload.Model,
+	}
+	for idx, call := range reply.ToolCalls {
+		if call.Id == "" {
+			call.Id = fmt.Sprintf("call_%s_%d", id, idx+1)
+		}
+		response.Output = append(response.Output, ResponseOutput{
+			ID:        fmt.Sprintf("fc_%s_%d", id, idx+1),
+			Type:      "function_call",
+			Status:    "completed",
+			CallID:    call.Id,
+			Name:      call.Name,
+			Arguments: call.Arguments,
+		})
+	}
+	if reply.Text != "" {
+		response.SetOutputText(reply.Text)
+	}
+	response.Usage.InputTokens = reply.Usage.InputTokens
+	response.Usage.InputTokensDetails.CachedTokens = reply.Usage.CachedTokens
+	response.Usage.OutputTokens = reply.Usage.OutputTokens
+	response.Usage.OutputTokensDetails.ReasoningTokens = reply.Usage.ReasoningTokens
+	response.Usage.TotalTokens = reply.Usage.Total()
+	return response
+}
+
+// lastInputText devuelve el texto del último mensaje de la entrada
+func lastInputText(input []any) string {
+	for idx := len(input) - 1; idx >= 0; idx-- {
+		if item, ok := input[idx].(map[string]any); ok {
+			if content, ok := item["content"].(string); ok {
+				return content
+			}
+			if output, ok := item["output"].(string); ok {
+				return output
+			}
+		}
+	}
+	return ""
+}
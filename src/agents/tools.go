@@ -0,0 +1,245 @@
+package agentspkg
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	configpkg "main/src/config"
+)
+
+type ToolsConfig = configpkg.ToolsConfig
+
+// Tool es una función Go que el modelo puede pedir ejecutar
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  map[string]any // JSON Schema de los argumentos
+	Dangerous   bool           // pide aprobación (y/n) antes de ejecutarse
+	Run         func(ctx context.Context, args json.RawMessage) (string, error)
+}
+
+func (t Tool) Spec() ToolSpec {
+	return ToolSpec{Name: t.Name, Description: t.Description, Parameters: t.Parameters}
+}
+
+// ToolRegistry guarda las herramientas disponibles para los agentes
+type ToolRegistry struct {
+	mu    sync.RWMutex
+	tools map[string]Tool
+}
+
+func NewToolRegistry() *ToolRegistry {
+	return &ToolRegistry{tools: map[string]Tool{}}
+}
+
+func (r *ToolRegistry) Register(tool Tool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tools[tool.Name] = tool
+}
+
+func (r *ToolRegistry) Get(name string) (Tool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tool, ok := r.tools[name]
+	return tool, ok
+}
+
+// List devuelve los nombres registrados en orden alfabético
+func (r *ToolRegistry) List() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.tools))
+	for name := range r.tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Select resuelve las herramientas declaradas para un agente
+func (r *ToolRegistry) Select(names []string) ([]Tool, error) {
+	var tools []Tool
+	for _, name := range names {
+		tool, ok := r.Get(name)
+		if !ok {
+			return nil, fmt.Errorf("tool %q not registered", name)
+		}
+		tools = append(tools, tool)
+	}
+	return tools, nil
+}
+
+/**
+ * BUILTIN TOOLS
+ */
+
+const (
+	defaultMaxFileSize    = 64 * 1024
+	defaultCommandTimeout = 10 * time.Second
+	maxCommandOutput      = 16 * 1024
+)
+
+// RegisterBuiltinTools añade read_file, list_threads y run_command
+func RegisterBuiltinTools(r *ToolRegistry, conf ToolsConfig, db *Database) error {
+	workspace := conf.Workspace
+	if workspace == "" {
+		workspace = "."
+	}
+	workspace, err := filepath.Abs(os.ExpandEnv(workspace))
+	if err != nil {
+		return err
+	}
+
+	maxSize := conf.MaxFileSize
+	if maxSize <= 0 {
+		maxSize = defaultMaxFileSize
+	}
+
+	timeout := defaultCommandTimeout
+	if conf.CommandTimeout != "" {
+		if timeout, err = time.ParseDuration(conf.CommandTimeout); err != nil {
+			return fmt.Errorf("command_timeout: %w", err)
+		}
+	}
+
+	r.Register(Tool{
+		Name:        "read_file",
+		Description: "Read a text file inside the workspace. The path is relative to the workspace root.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"path": map[string]any{"type": "string", "description": "File path relative to the workspace"},
+			},
+			"required": []string{"path"},
+		},
+		Run: func(ctx context.Context, args json.RawMessage) (string, error) {
+			var in struct {
+				Path string `json:"path"`
+			}
+			if err := json.Unmarshal(args, &in); err != nil {
+				return "", err
+			}
+			return readWorkspaceFile(workspace, in.Path, maxSize)
+		},
+	})
+
+	r.Register(Tool{
+		Name:        "list_threads",
+		Description: "List the conversation threads stored in the application.",
+		Parameters:  map[string]any{"type": "object", "properties": map[string]any{}},
+		Run: func(ctx context.Context, args json.RawMessage) (string, error) {
+			if db == nil {
+				return "", errors.New("database not available")
+			}
+			threads, err := db.ListThreads()
+			if err != nil {
+				return "", err
+			}
+			var sb strings.Builder
+			for _, thread := range threads {
+				fmt.Fprintf(&sb, "%s\t%s\t%s\n", thread.Id, thread.Name, thread.CreatedAt.Format(time.RFC3339))
+			}
+			if sb.Len() == 0 {
+				return "no threads", nil
+			}
+			return sb.String(), nil
+		},
+	})
+
+	r.Register(Tool{
+		Name:        "run_command",
+		Description: "Run an allowed command in the workspace and return its output. Allowed: " + strings.Join(conf.AllowedCommands, ", "),
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"command": map[string]any{"type": "string", "description": "Executable name"},
+				"args":    map[string]any{"type": "array", "items": map[string]any{"type": "string"}},
+			},
+			"required": []string{"command"},
+		},
+		Dangerous: true,
+		Run: func(ctx context.Context, args json.RawMessage) (string, error) {
+			var in struct {
+				Command string   `json:"command"`
+				Args    []string `json:"args"`
+			}
+			if err := json.Unmarshal(args, &in); err != nil {
+				return "", err
+			}
+			if !slices.Contains(conf.AllowedCommands, in.Command) {
+				return "", fmt.Errorf("command %q not allowed", in.Command)
+			}
+
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+
+			cmd := exec.CommandContext(ctx, in.Command, in.Args...)
+			cmd.Dir = workspace
+			output, err := cmd.CombinedOutput()
+			text := string(output)
+			if len(text) > maxCommandOutput {
+				text = text[:maxCommandOutput] + "\n[output truncated]"
+			}
+			if err != nil {
+				return text, fmt.Errorf("%s: %w", in.Command, err)
+			}
+			return text, nil
+		},
+	})
+
+	return nil
+}
+
+// readWorkspaceFile lee como mucho maxSize bytes de un archivo sin salir del workspace
+func readWorkspaceFile(workspace string, path string, maxSize int64) (string, error) {
+	full := filepath.Join(workspace, filepath.Clean("/"+path))
+	// los enlaces simbólicos tampoco pueden apuntar fuera //
+	resolved, err := filepath.EvalSymlinks(full)
+	if err != nil {
+		return "", err
+	}
+	root, err := filepath.EvalSymlinks(workspace)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("path %q outside workspace", path)
+	}
+
+	file, err := os.Open(resolved)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%q is a directory", path)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(file, maxSize))
+	if err != nil {
+		return "", err
+	}
+	text := string(data)
+	if info.Size() > maxSize {
+		text += fmt.Sprintf("\n[truncated: %d of %d bytes]", maxSize, info.Size())
+	}
+	return text, nil
+}
@@ -0,0 +1,257 @@
+package attachpkg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	configpkg "main/src/config"
+	modelpkg "main/src/model"
+)
+
+type AttachmentModel = modelpkg.AttachmentModel
+
+const (
+	defaultMaxFileSize  = 64 * 1024
+	defaultMaxTotalSize = 256 * 1024
+	defaultMaxFiles     = 20
+)
+
+// inlinePattern reconoce "@ruta" en el texto; para no confundirse con las
+// @menciones a agentes, la ruta debe contener "/" o "."
+var inlinePattern = regexp.MustCompile(`(?:^|\s)@([^\s@]*[/.][^\s@]*)`)
+
+// Attacher lee archivos del workspace para adjuntarlos a un mensaje
+type Attacher struct {
+	Workspace    string
+	MaxFileSize  int64
+	MaxTotalSize int64
+	MaxFiles     int
+}
+
+func NewAttacher(conf configpkg.AttachmentsConfig) (*Attacher, error) {
+	workspace := conf.Workspace
+	if workspace == "" {
+		workspace = "."
+	}
+	workspace, err := filepath.Abs(os.ExpandEnv(workspace))
+	if err != nil {
+		return nil, err
+	}
+	if resolved, err := filepath.EvalSymlinks(workspace); err == nil {
+		workspace = resolved
+	}
+
+	a := &Attacher{
+		Workspace:    workspace,
+		MaxFileSize:  conf.MaxFileSize,
+		MaxTotalSize: conf.MaxTotalSize,
+		MaxFiles:     conf.MaxFiles,
+	}
+	if a.MaxFileSize <= 0 {
+		a.MaxFileSize = defaultMaxFileSize
+	}
+	if a.MaxTotalSize <= 0 {
+		a.MaxTotalSize = defaultMaxTotalSize
+	}
+	if a.MaxFiles <= 0 {
+		a.MaxFiles = defaultMaxFiles
+	}
+	return a, nil
+}
+
+// Inline devuelve las rutas referenciadas con "@ruta" en el texto
+func (a *Attacher) Inline(text string) []string {
+	var paths []string
+	for _, match := range inlinePattern.FindAllStringSubmatch(text, -1) {
+		path := strings.TrimRight(match[1], ",;:)!?")
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+// Collect lee un archivo, un directorio (respetando .gitignore) o un glob
+// (admite "**"); ya son los adjuntos previos, para no repetirlos y sumar al límite.
+// Los archivos descartados se explican en los avisos
+func (a *Attacher) Collect(pattern string, already []AttachmentModel) ([]AttachmentModel, []string) {
+	var warnings []string
+	var total int64
+	seen := map[string]bool{}
+	for _, item := range already {
+		total += item.Size
+		seen[item.Path] = true
+	}
+	count := len(already)
+
+	var result []AttachmentModel
+	full := true
+	add := func(rel string) bool {
+		if seen[rel] {
+			return true
+		}
+		if count >= a.MaxFiles {
+			warnings = append(warnings, fmt.Sprintf("límite de %d archivos alcanzado", a.MaxFiles))
+			full = false
+			return false
+		}
+		item, err := a.read(rel)
+		if err != nil {
+			warnings = append(warnings, rel+": "+err.Error())
+			return true
+		}
+		if total+item.Size > a.MaxTotalSize {
+			warnings = append(warnings, fmt.Sprintf("%s: supera el total de %d bytes", rel, a.MaxTotalSize))
+			return true
+		}
+		seen[rel] = true
+		total += item.Size
+		count++
+		result = append(result, item)
+		return true
+	}
+
+	// Glob: se recorre el workspace comparando rutas relativas //
+	if strings.ContainsAny(pattern, "*?[") {
+		clean := strings.TrimPrefix(filepath.ToSlash(filepath.Clean("/"+pattern)), "/")
+		err := a.walk("", func(rel string) bool {
+			if !globMatch(clean, rel) {
+				return true
+			}
+			return add(rel)
+		})
+		if err != nil {
+			warnings = append(warnings, err.Error())
+		}
+		if len(result) == 0 && full {
+			warnings = append(warnings, pattern+": ningún archivo coincide")
+		}
+		return result, warnings
+	}
+
+	rel, info, err := a.resolve(pattern)
+	if err != nil {
+		return nil, append(warnings, pattern+": "+err.Error())
+	}
+	if !info.IsDir() {
+		add(rel)
+		return result, warnings
+	}
+
+	if err := a.walk(rel, add); err != nil {
+		warnings = append(warnings, err.Error())
+	}
+	if len(result) == 0 && full {
+		warnings = append(warnings, pattern+": directorio sin archivos adjuntables")
+	}
+	return result, warnings
+}
+
+// resolve devuelve la ruta relativa al workspace sin permitir salir de él
+func (a *Attacher) resolve(path string) (string, os.FileInfo, error) {
+	full := filepath.Join(a.Workspace, filepath.Clean("/"+path))
+	resolved, err := filepath.EvalSymlinks(full)
+	if err != nil {
+		return "", nil, errors.New("no existe")
+	}
+	rel, err := filepath.Rel(a.Workspace, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", nil, errors.New("fuera del workspace")
+	}
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return "", nil, err
+	}
+	if rel == "." {
+		rel = ""
+	}
+	return filepath.ToSlash(rel), info, nil
+}
+
+func (a *Attacher) walk(start string, fn func(rel string) bool) error {
+	return Walk(a.Workspace, start, fn)
+}
+
+// Walk recorre start (relativo a root) en orden léxico, saltando .git y lo
+// ignorado por los .gitignore de la raíz, de los directorios intermedios y de los
+// visitados; fn recibe cada archivo regular (ruta relativa a root) y false detiene el recorrido
+func Walk(root string, start string, fn func(rel string) bool) error {
+	ignore := &Ignore{}
+	ignore.Load(root, "")
+	dir := ""
+	for _, part := range strings.Split(start, "/") {
+		if part == "" {
+			continue
+		}
+		dir = filepath.Join(dir, part)
+		ignore.Load(root, dir)
+	}
+
+	base := filepath.Join(root, start)
+	return filepath.WalkDir(base, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(root, path)
+		rel = filepath.ToSlash(rel)
+		if entry.IsDir() {
+			if path == base {
+				return nil
+			}
+			if entry.Name() == ".git" || ignore.Match(rel, true) {
+				return filepath.SkipDir
+			}
+			ignore.Load(root, rel)
+			return nil
+		}
+		if !entry.Type().IsRegular() || ignore.Match(rel, false) {
+			return nil
+		}
+		if !fn(rel) {
+			return filepath.SkipAll
+		}
+		return nil
+	})
+}
+
+// read carga un archivo de texto dentro de los límites
+func (a *Attacher) read(rel string) (AttachmentModel, error) {
+	full := filepath.Join(a.Workspace, rel)
+	info, err := os.Stat(full)
+	if err != nil {
+		return AttachmentModel{}, err
+	}
+	if info.Size() > a.MaxFileSize {
+		return AttachmentModel{}, fmt.Errorf("%d bytes, el máximo es %d", info.Size(), a.MaxFileSize)
+	}
+
+	data, err := os.ReadFile(full)
+	if err != nil {
+		return AttachmentModel{}, err
+	}
+	if IsBinary(data) {
+		return AttachmentModel{}, errors.New("archivo binario")
+	}
+
+	return AttachmentModel{
+		Path:    rel,
+		Size:    int64(len(data)),
+		Content: string(data),
+	}, nil
+}
+
+// IsBinary detecta contenido no textual: bytes nulos o UTF-8 inválido
+func IsBinary(data []byte) bool {
+	head := data
+	if len(head) > 8000 {
+		head = head[:8000]
+	}
+	return bytes.IndexByte(head, 0) >= 0 || !utf8.Valid(data)
+}
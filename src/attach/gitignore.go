@@ -0,0 +1,123 @@
+package attachpkg
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreRule es una línea de .gitignore relativa al directorio que la declara
+type ignoreRule struct {
+	base     string // directorio del .gitignore, relativo al workspace ("" = raíz)
+	pattern  string
+	negate   bool // "!patrón" vuelve a incluir
+	dirOnly  bool // "patrón/" solo aplica a directorios
+	anchored bool // contiene "/" : se compara con la ruta completa desde base
+}
+
+// Ignore acumula las reglas de los .gitignore encontrados durante el recorrido
+type Ignore struct {
+	rules []ignoreRule
+}
+
+// Load añade las reglas del .gitignore de dir (relativo al workspace), si existe
+func (ig *Ignore) Load(root string, dir string) {
+	file, err := os.Open(filepath.Join(root, dir, ".gitignore"))
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule := ignoreRule{base: filepath.ToSlash(dir)}
+		if rule.base == "." {
+			rule.base = ""
+		}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			rule.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		rule.pattern = line
+		ig.rules = append(ig.rules, rule)
+	}
+}
+
+// Match indica si la ruta (relativa al workspace, con "/") está ignorada;
+// la última regla que coincide decide, como en git
+func (ig *Ignore) Match(rel string, isDir bool) bool {
+	rel = filepath.ToSlash(rel)
+	ignored := false
+	for _, rule := range ig.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		target := rel
+		if rule.base != "" {
+			if !strings.HasPrefix(rel, rule.base+"/") {
+				continue
+			}
+			target = strings.TrimPrefix(rel, rule.base+"/")
+		}
+		if rule.matches(target) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+func (r ignoreRule) matches(target string) bool {
+	if r.anchored {
+		return globMatch(r.pattern, target)
+	}
+	// sin "/" el patrón vale para el nombre en cualquier nivel //
+	return globMatch(r.pattern, path.Base(target))
+}
+
+// globMatch es path.Match con soporte de "**" (cero o más directorios)
+func globMatch(pattern string, name string) bool {
+	if !strings.Contains(pattern, "**") {
+		ok, _ := path.Match(pattern, name)
+		return ok
+	}
+
+	patternParts := strings.Split(pattern, "/")
+	nameParts := strings.Split(name, "/")
+	return matchParts(patternParts, nameParts)
+}
+
+func matchParts(pattern []string, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for skip := 0; skip <= len(name); skip++ {
+				if matchParts(pattern[1:], name[skip:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
@@ -0,0 +1,177 @@
+package budgetpkg
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	configpkg "main/src/config"
+	databasepkg "main/src/database"
+	modelpkg "main/src/model"
+)
+
+type Database = databasepkg.Database
+type BudgetModel = modelpkg.BudgetModel
+type UsageModel = modelpkg.UsageModel
+
+// Ámbitos de gasto que se controlan
+const (
+	ScopeThread = "thread"
+	ScopeDay    = "day"
+)
+
+var Scopes = []string{ScopeThread, ScopeDay}
+
+// Spent es lo consumido en un ámbito
+type Spent struct {
+	Tokens int
+	Cost   float64
+}
+
+// ExceededError indica que un límite se agotó y la petición no debe enviarse
+type ExceededError struct {
+	Scope string
+	Limit BudgetModel
+	Spent Spent
+}
+
+func (e *ExceededError) Error() string {
+	if e.Limit.Tokens > 0 && e.Spent.Tokens >= e.Limit.Tokens {
+		return fmt.Sprintf("budget %s exhausted: %d/%d tokens", e.Scope, e.Spent.Tokens, e.Limit.Tokens)
+	}
+	return fmt.Sprintf("budget %s exhausted: %.4f/%.4f", e.Scope, e.Spent.Cost, e.Limit.Cost)
+}
+
+// Budget calcula el gasto a partir de los mensajes guardados y lo compara
+// con los límites de config o, si se ajustaron con `/budget`, los de la base de datos
+type Budget struct {
+	db     *Database
+	config *configpkg.Config
+}
+
+func NewBudget(config *configpkg.Config, db *Database) *Budget {
+	return &Budget{db: db, config: config}
+}
+
+// Limit devuelve el límite vigente de un ámbito
+func (b *Budget) Limit(scope string) BudgetModel {
+	limit := BudgetModel{Scope: scope}
+	switch scope {
+	case ScopeThread:
+		limit.Tokens = b.config.Config.Budgets.Thread.Tokens
+		limit.Cost = b.config.Config.Budgets.Thread.Cost
+	case ScopeDay:
+		limit.Tokens = b.config.Config.Budgets.Day.Tokens
+		limit.Cost = b.config.Config.Budgets.Day.Cost
+	}
+
+	budgets, _ := b.db.ListBudgets()
+	for _, budget := range budgets {
+		if budget.Scope == scope {
+			return budget
+		}
+	}
+	return limit
+}
+
+// SetLimit guarda el límite de tokens o de coste de un ámbito
+func (b *Budget) SetLimit(scope string, kind string, value float64) error {
+	if scope != ScopeThread && scope != ScopeDay {
+		return fmt.Errorf("scope %q not supported", scope)
+	}
+	if value < 0 {
+		return fmt.Errorf("negative limit")
+	}
+
+	limit := b.Limit(scope)
+	switch kind {
+	case "tokens":
+		limit.Tokens = int(value)
+	case "cost":
+		limit.Cost = value
+	default:
+		return fmt.Errorf("limit %q not supported", kind)
+	}
+	return b.db.SetBudget(limit)
+}
+
+// Spent suma el consumo del ámbito: el hilo dado o el día de hoy
+func (b *Budget) Spent(scope string, threadId string) (Spent, error) {
+	var spent Spent
+	day := ""
+	switch scope {
+	case ScopeThread:
+		if threadId == "" {
+			return spent, nil
+		}
+	case ScopeDay:
+		threadId = ""
+		day = time.Now().Format("2006-01-02")
+	}
+
+	totals, err := b.db.UsageByModel(threadId, day)
+	if err != nil {
+		return spent, err
+	}
+	for _, total := range totals {
+		spent.Tokens += total.Total()
+		spent.Cost += b.Cost(total.UsageModel)
+	}
+	return spent, nil
+}
+
+// Check devuelve *ExceededError si algún límite del hilo o del día está agotado
+func (b *Budget) Check(threadId string) error {
+	for _, scope := range Scopes {
+		limit := b.Limit(scope)
+		if limit.Tokens == 0 && limit.Cost == 0 {
+			continue
+		}
+		spent, err := b.Spent(scope, threadId)
+		if err != nil {
+			return err
+		}
+		if (limit.Tokens > 0 && spent.Tokens >= limit.Tokens) ||
+			(limit.Cost > 0 && spent.Cost >= limit.Cost) {
+			return &ExceededError{Scope: scope, Limit: limit, Spent: spent}
+		}
+	}
+	return nil
+}
+
+// Cost estima el coste de un consumo con la tabla `prices`; 0 si el modelo no tiene precio
+func (b *Budget) Cost(usage UsageModel) float64 {
+	price, ok := b.Price(usage.Model)
+	if !ok {
+		return 0
+	}
+	cachedPrice := price.Cached
+	if cachedPrice == 0 {
+		cachedPrice = price.Input
+	}
+	uncached := usage.InputTokens - usage.CachedTokens
+	if uncached < 0 {
+		uncached = 0
+	}
+
+	return (float64(uncached)*price.Input +
+		float64(usage.CachedTokens)*cachedPrice +
+		float64(usage.OutputTokens)*price.Output) / 1_000_000
+}
+
+// Price busca el precio del modelo: coincidencia exacta o el prefijo más largo
+// (p. ej. "gpt-4o-mini" cubre "gpt-4o-mini-2024-07-18")
+func (b *Budget) Price(model string) (configpkg.PriceConfig, bool) {
+	var best configpkg.PriceConfig
+	found := false
+	for _, price := range b.config.Config.Prices {
+		if price.Model == model {
+			return price, true
+		}
+		if strings.HasPrefix(model, price.Model) && len(price.Model) > len(best.Model) {
+			best = price
+			found = true
+		}
+	}
+	return best, found
+}
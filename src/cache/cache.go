@@ -0,0 +1,72 @@
+package cachepkg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	configpkg "main/src/config"
+	databasepkg "main/src/database"
+	modelpkg "main/src/model"
+)
+
+type Database = databasepkg.Database
+type CacheStatsModel = modelpkg.CacheStatsModel
+
+const defaultTTL = 24 * time.Hour
+
+// Cache guarda respuestas de los proveedores en la base de datos para
+// contestar al instante las peticiones idénticas
+type Cache struct {
+	db  *Database
+	TTL time.Duration
+}
+
+// NewCache devuelve nil si la caché no está activada
+func NewCache(conf configpkg.CacheConfig, db *Database) (*Cache, error) {
+	if !conf.Enabled {
+		return nil, nil
+	}
+	ttl := defaultTTL
+	if conf.TTL != "" {
+		parsed, err := time.ParseDuration(conf.TTL)
+		if err != nil {
+			return nil, err
+		}
+		ttl = parsed
+	}
+	return &Cache{db: db, TTL: ttl}, nil
+}
+
+// Key resume en un hash todo lo que determina la respuesta (proveedor,
+// modelo, parámetros, instrucciones y contexto completo)
+func Key(parts any) (string, error) {
+	data, err := json.Marshal(parts)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Get devuelve la respuesta guardada, si existe y no ha caducado
+func (c *Cache) Get(key string) (string, bool) {
+	response, ok, err := c.db.CachedResponse(key)
+	if err != nil {
+		return "", false
+	}
+	return response, ok
+}
+
+func (c *Cache) Put(key string, model string, response string) error {
+	return c.db.CacheResponse(key, model, response, time.Now().Add(c.TTL))
+}
+
+func (c *Cache) Clear() (int64, error) {
+	return c.db.ClearCache()
+}
+
+func (c *Cache) Stats() (CacheStatsModel, error) {
+	return c.db.CacheStats()
+}
@@ -0,0 +1,175 @@
+package commandpkg
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	eventpkg "main/src/event"
+	managerpkg "main/src/manager"
+	modelpkg "main/src/model"
+	toolspkg "main/src/tools"
+)
+
+// AgentLoader recarga los agentes declarados en config y gestiona los
+// creados en caliente con `/agent add`
+type AgentLoader interface {
+	Reload() (added []string, updated []string, removed []string, err error)
+	Kinds() []modelpkg.AgentKindModel
+	Add(kind string, name string, params map[string]string) error
+	Remove(name string) error
+	SetEnabled(name string, enabled bool) error
+}
+
+func AgentCommand(c *Command, args []string) bool {
+	message := MessageModel{
+		Type:   modelpkg.TySystem,
+		Source: modelpkg.ScSystem,
+		Text:   "",
+	}
+
+	if len(args) == 0 {
+		args = []string{""}
+	}
+
+	if c.loader == nil {
+		message.Text = "No hay cargador de agentes configurado"
+		c.bus.Publish(eventpkg.EvtMessage, message)
+		return true
+	}
+
+	switch args[0] {
+	case "reload":
+		added, updated, removed, err := c.loader.Reload()
+		message.Text = "# Agentes recargados\n"
+		message.Text += "- Nuevos: " + joinOrDash(added) + "\n"
+		message.Text += "- Actualizados: " + joinOrDash(updated) + "\n"
+		message.Text += "- Eliminados: " + joinOrDash(removed) + "\n"
+		if err != nil {
+			message.Text += "\n**Errores**\n```\n" + err.Error() + "\n```\n"
+		}
+		message.Text += "\nSe recargan los agentes y el enrutado; precios, herramientas, personas y el resto de config.yaml se aplican al reiniciar"
+
+	case "kinds":
+		list := [][]string{}
+		for _, kind := range c.loader.Kinds() {
+			list = append(list, []string{kind.Name, kind.Description, joinOrDash(kind.Params)})
+		}
+		message.Text = "# Tipos de agente\n"
+		message.Text += toolspkg.TableStatGeneral([]string{"Tipo", "Descripción", "Parámetros"}, list)
+		message.Text += "\nTodos aceptan además los de reinicio y supervisión (`auto_restart`, `group`, `strategy`, `max_restarts`, `stop_timeout`...)."
+		message.Text += "\nUso: `/agent add <tipo> <nombre> [clave=valor ...]`"
+
+	case "add":
+		if len(args) < 3 {
+			message.Text = "Uso: `/agent add <tipo> <nombre> [clave=valor ...]`"
+			break
+		}
+		params, err := parseParams(strings.Join(args[3:], " "))
+		if err == nil {
+			err = c.loader.Add(args[1], args[2], params)
+		}
+		if err != nil {
+			message.Text = "Error al crear " + args[2] + ": " + err.Error()
+			break
+		}
+		message.Text = fmt.Sprintf("Agente **%s** (%s) registrado; arráncalo con `/start %s`", args[2], args[1], args[2])
+
+	case "remove":
+		if len(args) < 2 {
+			message.Text = "Uso: `/agent remove <nombre>`"
+			break
+		}
+		if err := c.loader.Remove(args[1]); err != nil {
+			message.Text = AgentErrorText(args[1], err)
+			break
+		}
+		message.Text = "Agente **" + args[1] + "** eliminado"
+
+	case "enable", "disable":
+		if len(args) < 2 {
+			message.Text = "Uso: `/agent " + args[0] + " <nombre>`"
+			break
+		}
+		enabled := args[0] == "enable"
+		if err := c.loader.SetEnabled(args[1], enabled); err != nil {
+			message.Text = AgentErrorText(args[1], err)
+			break
+		}
+		if enabled {
+			message.Text = "Agente **" + args[1] + "** habilitado; arráncalo con `/start " + args[1] + "`"
+		} else {
+			message.Text = "Agente **" + args[1] + "** deshabilitado y detenido"
+		}
+
+	default:
+		message.Text = "Uso: `/agent` reload|kinds|add|remove|enable|disable"
+	}
+
+	if len(message.Text) > 0 {
+		c.bus.Publish(eventpkg.EvtMessage, message)
+	}
+
+	// show command //
+	return true
+}
+
+// AgentErrorText explica los errores de las operaciones sobre un agente
+func AgentErrorText(name string, err error) string {
+	switch {
+	case errors.Is(err, managerpkg.ErrUnknownAgent):
+		return "No existe el agente " + name + "; consulta `/st`"
+	case errors.Is(err, managerpkg.ErrAgentDisabled):
+		return "El agente " + name + " está deshabilitado; habilítalo con `/agent enable " + name + "`"
+	case errors.Is(err, managerpkg.ErrForcedStop):
+		return "El agente " + name + " no terminó a tiempo: queda detenido y no se arrancará hasta que salga"
+	default:
+		return "Error en " + name + ": " + err.Error()
+	}
+}
+
+// parseParams separa "clave=valor" por espacios; los valores con espacios
+// van entre comillas: instructions="responde en inglés"
+func parseParams(text string) (map[string]string, error) {
+	params := map[string]string{}
+	var token strings.Builder
+	var quote rune
+	tokens := []string{}
+	for _, char := range text {
+		switch {
+		case quote != 0 && char == quote:
+			quote = 0
+		case quote == 0 && (char == '"' || char == '\''):
+			quote = char
+		case quote == 0 && char == ' ':
+			if token.Len() > 0 {
+				tokens = append(tokens, token.String())
+				token.Reset()
+			}
+		default:
+			token.WriteRune(char)
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("unterminated quote")
+	}
+	if token.Len() > 0 {
+		tokens = append(tokens, token.String())
+	}
+
+	for _, item := range tokens {
+		key, value, ok := strings.Cut(item, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("parameter %q is not key=value", item)
+		}
+		params[key] = value
+	}
+	return params, nil
+}
+
+func joinOrDash(list []string) string {
+	if len(list) == 0 {
+		return "-"
+	}
+	return strings.Join(list, ", ")
+}
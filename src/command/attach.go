@@ -0,0 +1,54 @@
+package commandpkg
+
+import (
+	"strconv"
+	"strings"
+
+	eventpkg "main/src/event"
+	modelpkg "main/src/model"
+	toolspkg "main/src/tools"
+)
+
+func AttachCommand(c *Command, args []string) bool {
+	message := MessageModel{
+		Type:   modelpkg.TySystem,
+		Source: modelpkg.ScSystem,
+		Text:   "",
+	}
+
+	switch {
+	case len(args) == 1 && args[0] == "-":
+		c.messages.Pending = nil
+		message.Text = "Adjuntos descartados"
+
+	case len(args) > 0:
+		items, warnings := c.messages.Stage(strings.Join(args, " "))
+		message.Text = "Adjuntados " + strconv.Itoa(len(items)) + " archivos al próximo mensaje\n"
+		for _, warning := range warnings {
+			message.Text += "\n- " + warning
+		}
+		fallthrough
+
+	default:
+		if len(c.messages.Pending) == 0 {
+			if message.Text == "" {
+				message.Text = "No hay adjuntos pendientes. Uso: `/attach` [ruta|glob] | `/attach` -"
+			}
+			break
+		}
+		list := [][]string{}
+		var total int64
+		for _, item := range c.messages.Pending {
+			total += item.Size
+			list = append(list, []string{item.Path, strconv.FormatInt(item.Size, 10)})
+		}
+		message.Text += "\n# Adjuntos pendientes\n"
+		message.Text += toolspkg.TableStatGeneral([]string{"Archivo", "Bytes"}, list)
+		message.Text += "\nTotal: " + strconv.FormatInt(total, 10) + " bytes"
+	}
+
+	c.bus.Publish(eventpkg.EvtMessage, message)
+
+	// show command //
+	return true
+}
@@ -0,0 +1,69 @@
+package commandpkg
+
+import (
+	"strconv"
+	"strings"
+
+	eventpkg "main/src/event"
+	modelpkg "main/src/model"
+)
+
+// humanMessages devuelve los mensajes humanos de la rama activa del hilo actual
+func humanMessages(c *Command) []MessageModel {
+	if c.messages.Thread == nil {
+		return nil
+	}
+	messages, _ := c.db.ListMessageByThreadId(c.messages.Thread.Id, false)
+
+	var human []MessageModel
+	for _, msg := range messages {
+		if msg.Source == modelpkg.ScHuman && msg.Type == modelpkg.TyText {
+			human = append(human, msg)
+		}
+	}
+	return human
+}
+
+// RegenCommand vuelve a enviar el último mensaje humano en una rama nueva
+func RegenCommand(c *Command, args []string) bool {
+	human := humanMessages(c)
+	if len(human) == 0 {
+		c.bus.Publish(eventpkg.EvtMessage, MessageModel{
+			Type:   modelpkg.TySystem,
+			Source: modelpkg.ScSystem,
+			Text:   "No hay mensajes para regenerar",
+		})
+		return true
+	}
+
+	last := human[len(human)-1]
+	c.bus.Publish(eventpkg.EvtMessage, c.messages.Branch(last, last.Text))
+
+	// no show command //
+	return false
+}
+
+// EditCommand reescribe el mensaje humano N de la rama y lo reenvía en una rama nueva
+func EditCommand(c *Command, args []string) bool {
+	message := MessageModel{
+		Type:   modelpkg.TySystem,
+		Source: modelpkg.ScSystem,
+		Text:   "Uso: `/edit` [N] [texto] (N = número del mensaje humano, ver `#N`)",
+	}
+
+	human := humanMessages(c)
+	if len(args) < 2 {
+		c.bus.Publish(eventpkg.EvtMessage, message)
+		return true
+	}
+	idx, err := strconv.Atoi(args[0])
+	if err != nil || idx < 1 || idx > len(human) {
+		c.bus.Publish(eventpkg.EvtMessage, message)
+		return true
+	}
+
+	c.bus.Publish(eventpkg.EvtMessage, c.messages.Branch(human[idx-1], strings.Join(args[1:], " ")))
+
+	// no show command //
+	return false
+}
@@ -0,0 +1,93 @@
+package commandpkg
+
+import (
+	"fmt"
+	"strconv"
+
+	budgetpkg "main/src/budget"
+	eventpkg "main/src/event"
+	modelpkg "main/src/model"
+	toolspkg "main/src/tools"
+)
+
+func BudgetCommand(c *Command, args []string) bool {
+	message := MessageModel{
+		Type:   modelpkg.TySystem,
+		Source: modelpkg.ScSystem,
+		Text:   "",
+	}
+
+	if c.budget == nil {
+		message.Text = "No hay presupuestos configurados"
+		c.bus.Publish(eventpkg.EvtMessage, message)
+		return true
+	}
+
+	switch len(args) {
+	case 0:
+		threadId := ""
+		if c.messages.Thread != nil {
+			threadId = c.messages.Thread.Id
+		}
+
+		list := [][]string{}
+		for _, scope := range budgetpkg.Scopes {
+			limit := c.budget.Limit(scope)
+			spent, err := c.budget.Spent(scope, threadId)
+			if err != nil {
+				message.Text = "**Error**: " + err.Error()
+				break
+			}
+			list = append(list, []string{
+				scope,
+				strconv.Itoa(spent.Tokens) + " / " + limitText(strconv.Itoa(limit.Tokens), limit.Tokens == 0),
+				fmt.Sprintf("%.4f", spent.Cost) + " / " + limitText(fmt.Sprintf("%.4f", limit.Cost), limit.Cost == 0),
+			})
+		}
+		if message.Text != "" {
+			break
+		}
+		message.Text = "# Presupuestos\n"
+		message.Text += toolspkg.TableStatGeneral([]string{"Ámbito", "Tokens", "Coste"}, list)
+		message.Text += "\nEl coste solo cuenta modelos con precio en `prices`"
+
+	case 2, 3:
+		// /budget <thread|day> off | /budget <thread|day> <tokens|cost> <valor> //
+		scope := args[0]
+		var err error
+		if len(args) == 2 && args[1] == "off" {
+			if err = c.budget.SetLimit(scope, "tokens", 0); err == nil {
+				err = c.budget.SetLimit(scope, "cost", 0)
+			}
+		} else if len(args) == 3 {
+			var value float64
+			value, err = strconv.ParseFloat(args[2], 64)
+			if err == nil {
+				err = c.budget.SetLimit(scope, args[1], value)
+			}
+		} else {
+			err = fmt.Errorf("argumentos inválidos")
+		}
+		if err != nil {
+			message.Text = "Error al ajustar el presupuesto: " + err.Error()
+			break
+		}
+		limit := c.budget.Limit(scope)
+		message.Text = fmt.Sprintf("Presupuesto %s: %d tokens, coste %.4f (0 = sin límite)", scope, limit.Tokens, limit.Cost)
+
+	default:
+		message.Text = "Uso: `/budget` [thread|day] [tokens|cost] [valor] | `/budget` [thread|day] off"
+	}
+
+	c.bus.Publish(eventpkg.EvtMessage, message)
+
+	// show command //
+	return true
+}
+
+func limitText(value string, unlimited bool) string {
+	if unlimited {
+		return "∞"
+	}
+	return value
+}
@@ -0,0 +1,61 @@
+package commandpkg
+
+import (
+	"strconv"
+
+	eventpkg "main/src/event"
+	modelpkg "main/src/model"
+	toolspkg "main/src/tools"
+)
+
+func CacheCommand(c *Command, args []string) bool {
+	message := MessageModel{
+		Type:   modelpkg.TySystem,
+		Source: modelpkg.ScSystem,
+		Text:   "",
+	}
+
+	if c.cache == nil {
+		message.Text = "La caché de respuestas está desactivada: configura `cache.enabled`"
+		c.bus.Publish(eventpkg.EvtMessage, message)
+		return true
+	}
+
+	sub := "stats"
+	if len(args) > 0 {
+		sub = args[0]
+	}
+
+	switch sub {
+	case "stats":
+		stats, err := c.cache.Stats()
+		if err != nil {
+			message.Text = "**Error**: " + err.Error()
+			break
+		}
+		message.Text = "# Caché de respuestas\n"
+		message.Text += toolspkg.TableStatGeneral([]string{"Entradas", "Caducadas", "Aciertos", "Bytes", "TTL"}, [][]string{{
+			strconv.Itoa(stats.Entries),
+			strconv.Itoa(stats.Expired),
+			strconv.Itoa(stats.Hits),
+			strconv.FormatInt(stats.Bytes, 10),
+			c.cache.TTL.String(),
+		}})
+
+	case "clear":
+		removed, err := c.cache.Clear()
+		if err != nil {
+			message.Text = "Error al vaciar la caché: " + err.Error()
+			break
+		}
+		message.Text = "Caché vaciada: " + strconv.FormatInt(removed, 10) + " respuestas borradas"
+
+	default:
+		message.Text = "Uso: `/cache` [stats|clear]"
+	}
+
+	c.bus.Publish(eventpkg.EvtMessage, message)
+
+	// show command //
+	return true
+}
@@ -1,18 +1,20 @@
 package commandpkg
 
 import (
-	"fmt"
 	"log/slog"
-	"strconv"
 	"strings"
 
+	budgetpkg "main/src/budget"
 	buspkg "main/src/bus"
+	cachepkg "main/src/cache"
 	configpkg "main/src/config"
 	databasepkg "main/src/database"
 	eventpkg "main/src/event"
+	indexpkg "main/src/index"
 	managerpkg "main/src/manager"
 	messagepkg "main/src/message"
 	modelpkg "main/src/model"
+	settingspkg "main/src/settings"
 	toolspkg "main/src/tools"
 )
 
@@ -26,6 +28,11 @@ type Command struct {
 	db       *databasepkg.Database
 	mgr      *managerpkg.Manager
 	messages *messagepkg.MessageList
+	loader   AgentLoader
+	budget   *budgetpkg.Budget
+	settings *settingspkg.Settings
+	index    *indexpkg.Index
+	cache    *cachepkg.Cache
 }
 
 func NewCommand(
@@ -46,6 +53,31 @@ func NewCommand(
 	}
 }
 
+// SetAgentLoader conecta el cargador de agentes usado por `/agent reload`
+func (c *Command) SetAgentLoader(loader AgentLoader) {
+	c.loader = loader
+}
+
+// SetBudget conecta los presupuestos mostrados y ajustados por `/budget`
+func (c *Command) SetBudget(budget *budgetpkg.Budget) {
+	c.budget = budget
+}
+
+// SetSettings conecta los ajustes de `/model` y `/set`
+func (c *Command) SetSettings(settings *settingspkg.Settings) {
+	c.settings = settings
+}
+
+// SetIndex conecta el índice local de `/index`
+func (c *Command) SetIndex(index *indexpkg.Index) {
+	c.index = index
+}
+
+// SetCache conecta la caché de respuestas de `/cache`
+func (c *Command) SetCache(cache *cachepkg.Cache) {
+	c.cache = cache
+}
+
 func (c *Command) IsCommandThenRun(text string) (bool, bool) {
 	isCmd, parts := c.IsCommand(text)
 	if !isCmd || len(parts) == 0 {
@@ -107,30 +139,44 @@ func (c *Command) Execute(cmd string, args []string) bool {
 	case "th":
 		return ThreadCommand(c, args)
 
-	case "st":
-		agents := c.mgr.ListAgents()
+	case "agent":
+		return AgentCommand(c, args)
 
-		if len(agents) == 0 {
-			message.Text = "No hay agentes registrados"
-			c.bus.Publish(eventpkg.EvtMessage, message)
-			break
-		}
+	case "usage":
+		return UsageCommand(c, args)
 
-		list := [][]string{}
-		for idx, agent := range agents {
-			state := agent.State
-			if agent.Restarts > 0 {
-				state += fmt.Sprintf(" (%d reinicios)", agent.Restarts)
-			}
-			if agent.LastErr != nil {
-				state += " ⚠️ " + agent.LastErr.Error()
-			}
-			list = append(list, []string{strconv.Itoa(idx + 1), agent.Name, state})
-		}
-		message.Text = "# Lista de agentes\n"
-		message.Text += toolspkg.TableStatGeneral([]string{"#", "Nombre", "Estado"}, list)
+	case "budget":
+		return BudgetCommand(c, args)
 
-		c.bus.Publish(eventpkg.EvtMessage, message)
+	case "model":
+		return ModelCommand(c, args)
+
+	case "set":
+		return SetCommand(c, args)
+
+	case "system":
+		return SystemCommand(c, args)
+
+	case "persona":
+		return PersonaCommand(c, args)
+
+	case "index":
+		return IndexCommand(c, args)
+
+	case "cache":
+		return CacheCommand(c, args)
+
+	case "attach":
+		return AttachCommand(c, args)
+
+	case "regen":
+		return RegenCommand(c, args)
+
+	case "edit":
+		return EditCommand(c, args)
+
+	case "st":
+		return StatusCommand(c, args)
 
 	case "start":
 		if len(args) < 1 {
@@ -141,7 +187,7 @@ func (c *Command) Execute(cmd string, args []string) bool {
 		name := args[0]
 		err := c.mgr.StartAgent(name)
 		if err != nil {
-			message.Text = "Error al iniciar " + name + ": " + err.Error()
+			message.Text = AgentErrorText(name, err)
 		} else {
 			message.Text = "Iniciando " + name
 		}
@@ -156,12 +202,19 @@ func (c *Command) Execute(cmd string, args []string) bool {
 		name := args[0]
 		err := c.mgr.StopAgent(name)
 		if err != nil {
-			message.Text = "Error al detener " + name + ": " + err.Error()
+			message.Text = AgentErrorText(name, err)
 		} else {
 			message.Text = "Deteniendo " + name
 		}
 		c.bus.Publish(eventpkg.EvtMessage, message)
 
+	case "cancel":
+		target := ""
+		if len(args) > 0 {
+			target = args[0]
+		}
+		c.bus.Publish(eventpkg.EvtCancel, target)
+
 	case "restart":
 		if len(args) < 1 {
 			message.Text = "Uso: /restart <agente>"
@@ -171,10 +224,11 @@ func (c *Command) Execute(cmd string, args []string) bool {
 		name := args[0]
 		err := c.mgr.RestartAgent(name)
 		if err != nil {
-			message.Text = "Error al reiniciar " + name + ": " + err.Error()
+			message.Text = AgentErrorText(name, err)
 		} else {
 			message.Text = "Reiniciando " + name
 		}
+		c.bus.Publish(eventpkg.EvtMessage, message)
 
 	default:
 		message.Text = "**Command not found**"
@@ -0,0 +1,79 @@
+package commandpkg
+
+import (
+	"strconv"
+	"time"
+
+	eventpkg "main/src/event"
+	modelpkg "main/src/model"
+	toolspkg "main/src/tools"
+)
+
+func IndexCommand(c *Command, args []string) bool {
+	message := MessageModel{
+		Type:   modelpkg.TySystem,
+		Source: modelpkg.ScSystem,
+		Text:   "",
+	}
+
+	if c.index == nil {
+		message.Text = "El índice local está desactivado: configura `retrieval.dir`"
+		c.bus.Publish(eventpkg.EvtMessage, message)
+		return true
+	}
+
+	sub := "status"
+	if len(args) > 0 {
+		sub = args[0]
+	}
+
+	switch sub {
+	case "status":
+		status, err := c.index.Status()
+		if err != nil {
+			message.Text = "**Error**: " + err.Error()
+			break
+		}
+		indexedAt := "nunca (usa `/index rebuild`)"
+		if !status.IndexedAt.IsZero() {
+			indexedAt = status.IndexedAt.Format("2006-01-02 15:04:05")
+		}
+		message.Text = "# Índice local\n"
+		message.Text += toolspkg.TableStatGeneral([]string{"Carpeta", "Archivos", "Fragmentos", "Indexado", "Top-k"}, [][]string{{
+			c.index.Dir,
+			strconv.Itoa(status.Files),
+			strconv.Itoa(status.Chunks),
+			indexedAt,
+			strconv.Itoa(c.index.TopK),
+		}})
+
+	case "rebuild":
+		// Indexar una carpeta grande no debe bloquear la interfaz //
+		message.Text = "Reconstruyendo el índice de " + c.index.Dir + "..."
+		go func() {
+			started := time.Now()
+			status, skipped, err := c.index.Rebuild()
+			result := MessageModel{
+				Type:   modelpkg.TySystem,
+				Source: modelpkg.ScSystem,
+			}
+			if err != nil {
+				c.logger.Error("Failed to rebuild index", "error", err)
+				result.Text = "Error al reconstruir el índice: " + err.Error()
+			} else {
+				result.Text = "Índice reconstruido: " + strconv.Itoa(status.Files) + " archivos, " +
+					strconv.Itoa(status.Chunks) + " fragmentos, " + strconv.Itoa(skipped) + " omitidos (" +
+					time.Since(started).Round(time.Millisecond).String() + ")"
+			}
+			c.bus.Publish(eventpkg.EvtMessage, result)
+		}()
+
+	default:
+		message.Text = "Uso: `/index` [status|rebuild]"
+	}
+
+	c.bus.Publish(eventpkg.EvtMessage, message)
+
+	// show command //
+	return true
+}
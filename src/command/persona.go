@@ -0,0 +1,163 @@
+package commandpkg
+
+import (
+	"strings"
+
+	configpkg "main/src/config"
+	eventpkg "main/src/event"
+	modelpkg "main/src/model"
+	toolspkg "main/src/tools"
+)
+
+// SystemCommand muestra o cambia las instrucciones del thread: `/system [texto|-]`
+func SystemCommand(c *Command, args []string) bool {
+	message := MessageModel{
+		Type:   modelpkg.TySystem,
+		Source: modelpkg.ScSystem,
+		Text:   "",
+	}
+
+	thread := c.messages.Thread
+	switch {
+	case thread == nil:
+		message.Text = "No hay thread seleccionado"
+
+	case len(args) == 0:
+		if thread.Instructions == "" {
+			message.Text = "El thread no tiene instrucciones; usa `/system` [texto]"
+			break
+		}
+		message.Text = "# Instrucciones del thread\n" + thread.Instructions
+
+	default:
+		instructions := strings.Join(args, " ")
+		if instructions == "-" {
+			instructions = ""
+		}
+		thread.Instructions = instructions
+		thread.Persona = ""
+		c.updateThread(*thread)
+		if instructions == "" {
+			message.Text = "Instrucciones del thread eliminadas"
+		} else {
+			message.Text = "Instrucciones del thread actualizadas"
+		}
+	}
+
+	c.bus.Publish(eventpkg.EvtMessage, message)
+
+	// show command //
+	return true
+}
+
+// PersonaCommand gestiona la biblioteca de personas de config
+func PersonaCommand(c *Command, args []string) bool {
+	message := MessageModel{
+		Type:   modelpkg.TySystem,
+		Source: modelpkg.ScSystem,
+		Text:   "",
+	}
+
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	switch args[0] {
+	case "list":
+		personas := c.config.Config.Personas
+		if len(personas) == 0 {
+			message.Text = "No hay personas en config"
+			break
+		}
+		list := [][]string{}
+		for _, persona := range personas {
+			name := persona.Name
+			if name == c.config.Config.DefaultPersona {
+				name += " *"
+			}
+			list = append(list, []string{
+				name,
+				dash(persona.Model),
+				toolspkg.CutString(strings.ReplaceAll(persona.Instructions, "\n", " "), 0, 60),
+			})
+		}
+		message.Text = "# Personas\n"
+		message.Text += toolspkg.TableStatGeneral([]string{"Nombre", "Modelo", "Instrucciones"}, list)
+		message.Text += "\n`*` = persona de los threads nuevos"
+
+	case "show":
+		if len(args) < 2 {
+			message.Text = "Uso: `/persona` show [nombre]"
+			break
+		}
+		persona, ok := c.config.Persona(args[1])
+		if !ok {
+			message.Text = "Persona no encontrada: " + args[1]
+			break
+		}
+		message.Text = "# " + persona.Name + "\n"
+		if persona.Model != "" {
+			message.Text += "Modelo: `" + persona.Model + "`\n\n"
+		}
+		message.Text += persona.Instructions
+
+	case "use":
+		if len(args) < 2 {
+			message.Text = "Uso: `/persona` use [nombre]"
+			break
+		}
+		if c.messages.Thread == nil {
+			message.Text = "No hay thread seleccionado"
+			break
+		}
+		persona, ok := c.config.Persona(args[1])
+		if !ok {
+			message.Text = "Persona no encontrada: " + args[1]
+			break
+		}
+		c.applyPersona(c.messages.Thread, persona)
+		message.Text = "Thread [" + c.messages.Thread.Id + "] con la persona " + persona.Name
+
+	default:
+		message.Text = "Uso: `/persona` [list|show|use] [nombre]"
+	}
+
+	c.bus.Publish(eventpkg.EvtMessage, message)
+
+	// show command //
+	return true
+}
+
+// ApplyDefaultPersona aplica `default_persona` a un thread recién creado
+func (c *Command) ApplyDefaultPersona(thread *ThreadModel) {
+	if c.config.Config.DefaultPersona == "" {
+		return
+	}
+	persona, ok := c.config.Persona(c.config.Config.DefaultPersona)
+	if !ok {
+		c.logger.Warn("default persona not found", "persona", c.config.Config.DefaultPersona)
+		return
+	}
+	c.applyPersona(thread, persona)
+}
+
+// applyPersona copia las instrucciones al thread y fija su modelo si la persona lo define
+func (c *Command) applyPersona(thread *ThreadModel, persona configpkg.PersonaConfig) {
+	thread.Instructions = persona.Instructions
+	thread.Persona = persona.Name
+	c.updateThread(*thread)
+
+	if persona.Model != "" && c.settings != nil {
+		c.settings.Set(thread.Id, "model", persona.Model)
+	}
+}
+
+// updateThread guarda el thread y refresca su copia en la lista
+func (c *Command) updateThread(thread ThreadModel) {
+	c.db.UpdateThread(thread)
+	for idx := range c.messages.Threads {
+		if c.messages.Threads[idx].Id == thread.Id {
+			c.messages.Threads[idx] = thread
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package commandpkg
+
+import (
+	"slices"
+	"strings"
+
+	eventpkg "main/src/event"
+	modelpkg "main/src/model"
+	settingspkg "main/src/settings"
+	toolspkg "main/src/tools"
+)
+
+// ModelCommand cambia el modelo: `/model <nombre|-> [-g]`
+func ModelCommand(c *Command, args []string) bool {
+	if len(args) == 0 {
+		return SetCommand(c, nil)
+	}
+	return SetCommand(c, append([]string{"model"}, args...))
+}
+
+// SetCommand ajusta un parámetro del modelo en el hilo actual o, con -g, para todos
+//
+//	/set                      muestra los ajustes
+//	/set <clave> <valor> [-g] guarda el ajuste
+//	/set <clave> - [-g]       lo elimina
+func SetCommand(c *Command, args []string) bool {
+	message := MessageModel{
+		Type:   modelpkg.TySystem,
+		Source: modelpkg.ScSystem,
+		Text:   "",
+	}
+
+	if c.settings == nil {
+		message.Text = "No hay ajustes disponibles"
+		c.bus.Publish(eventpkg.EvtMessage, message)
+		return true
+	}
+
+	global := slices.Contains(args, "-g")
+	args = slices.DeleteFunc(slices.Clone(args), func(arg string) bool { return arg == "-g" })
+
+	threadId := ""
+	if c.messages.Thread != nil {
+		threadId = c.messages.Thread.Id
+	}
+
+	switch len(args) {
+	case 0:
+		list := [][]string{}
+		for _, key := range settingspkg.Keys {
+			thread := ""
+			if threadId != "" {
+				thread = c.settings.Raw(threadId)[key]
+			}
+			list = append(list, []string{key, dash(c.settings.Raw(settingspkg.Global)[key]), dash(thread)})
+		}
+		message.Text = "# Ajustes del modelo\n"
+		message.Text += toolspkg.TableStatGeneral([]string{"Clave", "Global", "Thread"}, list)
+		message.Text += "\nEl valor del thread manda sobre el global; `-` = valor del agente"
+
+	case 2:
+		scope := settingspkg.Global
+		if !global {
+			if threadId == "" {
+				message.Text = "No hay thread seleccionado; usa `-g` para un ajuste global"
+				break
+			}
+			scope = threadId
+		}
+		if err := c.settings.Set(scope, args[0], args[1]); err != nil {
+			message.Text = "Error al guardar el ajuste: " + err.Error()
+			break
+		}
+		where := "en este thread"
+		if global {
+			where = "global"
+		}
+		message.Text = "Ajuste `" + args[0] + "` = `" + args[1] + "` (" + where + ")"
+
+	default:
+		message.Text = "Uso: `/set` [" + strings.Join(settingspkg.Keys, "|") + "] [valor|-] (-g)"
+	}
+
+	c.bus.Publish(eventpkg.EvtMessage, message)
+
+	// show command //
+	return true
+}
+
+func dash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
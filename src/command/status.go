@@ -0,0 +1,107 @@
+package commandpkg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	eventpkg "main/src/event"
+	managerpkg "main/src/manager"
+	modelpkg "main/src/model"
+	toolspkg "main/src/tools"
+)
+
+func StatusCommand(c *Command, args []string) bool {
+	message := MessageModel{
+		Type:   modelpkg.TySystem,
+		Source: modelpkg.ScSystem,
+		Text:   "",
+	}
+
+	// /st <agente>: detalle con la traza del último panic //
+	if len(args) > 0 {
+		agent, ok := c.mgr.AgentStatus(args[0])
+		if !ok {
+			message.Text = "No existe el agente " + args[0]
+			c.bus.Publish(eventpkg.EvtMessage, message)
+			return true
+		}
+
+		lastErr := "-"
+		if agent.LastErr != nil {
+			lastErr = agent.LastErr.Error()
+		}
+		group := "-"
+		if agent.Group != "" {
+			group = agent.Group + " · " + agent.Strategy
+		}
+		message.Text = "# Agente " + agent.Name + "\n"
+		message.Text += toolspkg.TableStatGeneral([]string{"Estado", "Grupo", "Reinicios", "Panics", "Último error"}, [][]string{{
+			agentState(agent),
+			group,
+			strconv.Itoa(agent.Restarts),
+			strconv.Itoa(agent.Panics),
+			lastErr,
+		}})
+		if agent.LastPanic != nil {
+			message.Text += fmt.Sprintf("\n## Último panic (%s)\n`%s`\n\n```\n%s\n```",
+				agent.LastPanic.At.Format("2006-01-02 15:04:05"),
+				agent.LastPanic.Error(),
+				strings.TrimRight(agent.LastPanic.Stack, "\n"),
+			)
+		}
+		c.bus.Publish(eventpkg.EvtMessage, message)
+		return true
+	}
+
+	agents := c.mgr.ListAgents()
+	if len(agents) == 0 {
+		message.Text = "No hay agentes registrados"
+		c.bus.Publish(eventpkg.EvtMessage, message)
+		return true
+	}
+
+	list := [][]string{}
+	panicked, failed := false, false
+	for idx, agent := range agents {
+		state := agentState(agent)
+		if agent.Restarts > 0 {
+			state += fmt.Sprintf(" (%d reinicios)", agent.Restarts)
+		}
+		if agent.LastErr != nil {
+			state += " ⚠️ " + agent.LastErr.Error()
+		}
+		panicked = panicked || agent.LastPanic != nil
+		failed = failed || agent.State == modelpkg.AgentFailed
+		list = append(list, []string{strconv.Itoa(idx + 1), agent.Name, state})
+	}
+	message.Text = "# Lista de agentes\n"
+	message.Text += toolspkg.TableStatGeneral([]string{"#", "Nombre", "Estado"}, list)
+	if panicked {
+		message.Text += "\nUsa `/st <agente>` para ver la traza del último panic"
+	}
+	if failed {
+		message.Text += "\nLos agentes failed superaron sus reinicios; vuelve a arrancarlos con `/start <agente>`"
+	}
+
+	c.bus.Publish(eventpkg.EvtMessage, message)
+
+	// show command //
+	return true
+}
+
+// agentState marca los agentes cuya última caída fue un panic o cuya
+// última parada fue forzada
+func agentState(agent managerpkg.AgentStatus) string {
+	state := agent.State
+	if agent.Panicked {
+		state += " · panicked"
+	}
+	if agent.Forced {
+		state += " · forced stop"
+	}
+	if agent.Disabled {
+		state += " · disabled"
+	}
+	return state
+}
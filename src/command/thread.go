@@ -20,20 +20,46 @@ func ThreadCommand(c *Command, args []string) bool {
 
 	switch args[0] {
 	case "-c":
-		thread, _ := c.db.CreateThread(ThreadModel{
+		// /th -c [nombre] (-p [persona]) //
+		if len(args) < 2 {
+			message.Text = "Uso: `/th` -c [name] (-p [persona])"
+			break
+		}
+		thread, err := c.db.CreateThread(ThreadModel{
 			Name:      args[1],
 			CreatedAt: time.Now(),
 		})
-		message.Text = "Nuevo Thread [" + thread.Id + "] " + args[1]
+		if err != nil {
+			message.Text = "Error al crear el thread: " + err.Error()
+			break
+		}
+		if len(args) > 3 && args[2] == "-p" {
+			persona, ok := c.config.Persona(args[3])
+			if !ok {
+				message.Text = "Persona no encontrada: " + args[3] + "\n"
+			} else {
+				c.applyPersona(thread, persona)
+			}
+		} else {
+			c.ApplyDefaultPersona(thread)
+		}
+		message.Text += "Nuevo Thread [" + thread.Id + "] " + args[1]
+		if thread.Persona != "" {
+			message.Text += " (persona " + thread.Persona + ")"
+		}
 		c.messages.Messages = []MessageModel{}
 
 	case "-l":
 		list := [][]string{}
 		for idx, thread := range c.messages.Threads {
-			list = append(list, []string{strconv.Itoa(idx + 1), thread.Id, thread.Name})
+			unread := ""
+			if count := c.messages.Unread[thread.Id]; count > 0 {
+				unread = strconv.Itoa(count)
+			}
+			list = append(list, []string{strconv.Itoa(idx + 1), thread.Id, thread.Name, unread})
 		}
 		message.Text = "# Lista de threads\n"
-		message.Text += toolspkg.TableStatGeneral([]string{"#", "ID", "Nombre"}, list)
+		message.Text += toolspkg.TableStatGeneral([]string{"#", "ID", "Nombre", "Sin leer"}, list)
 
 	case "-u":
 		ok, idx := ThreadCommandValidation(3, args, c.messages.Threads)
@@ -57,8 +83,7 @@ func ThreadCommand(c *Command, args []string) bool {
 			all = true
 		}
 		thread := c.messages.Threads[idx-1]
-		c.messages.Thread = &thread
-		c.messages.Messages, _ = c.db.ListMessageByThreadId(thread.Id, all)
+		c.messages.SelectThread(thread, all)
 
 		// no show command //
 		return false
@@ -71,8 +96,30 @@ func ThreadCommand(c *Command, args []string) bool {
 		}
 		thread := c.messages.Threads[idx-1]
 		c.db.DeleteThread(thread)
+		delete(c.messages.Unread, thread.Id)
 		message.Text = "Delete Thread [" + thread.Id + "] " + args[1]
 
+	case "-a":
+		if c.messages.Thread == nil || len(args) < 2 {
+			message.Text = "Uso: `/th` -a [agent|-] (sobre el thread seleccionado)"
+			break
+		}
+		name := args[1]
+		if name == "-" {
+			name = ""
+		} else if !c.mgr.Has(name) {
+			message.Text = "Agente no registrado: " + name
+			break
+		}
+		thread := c.messages.Thread
+		thread.DefaultAgent = name
+		c.updateThread(*thread)
+		if name == "" {
+			message.Text = "Thread [" + thread.Id + "] sin agente por defecto"
+		} else {
+			message.Text = "Thread [" + thread.Id + "] atendido por @" + name
+		}
+
 	default:
 		message.Text = "Flag no reconocido"
 	}
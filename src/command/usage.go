@@ -0,0 +1,73 @@
+package commandpkg
+
+import (
+	"strconv"
+
+	eventpkg "main/src/event"
+	modelpkg "main/src/model"
+	toolspkg "main/src/tools"
+)
+
+var usageGroups = []struct{ group, title string }{
+	{"thread", "Por thread"},
+	{"agent", "Por agente"},
+	{"model", "Por modelo"},
+	{"day", "Por día"},
+}
+
+func UsageCommand(c *Command, args []string) bool {
+	message := MessageModel{
+		Type:   modelpkg.TySystem,
+		Source: modelpkg.ScSystem,
+		Text:   "# Consumo de tokens\n",
+	}
+
+	group := ""
+	if len(args) > 0 {
+		group = args[0]
+	}
+
+	found := false
+	for _, item := range usageGroups {
+		if group != "" && group != item.group {
+			continue
+		}
+		found = true
+
+		totals, err := c.db.UsageTotals(item.group)
+		if err != nil {
+			message.Text += "\n**Error**: " + err.Error() + "\n"
+			break
+		}
+
+		list := [][]string{}
+		for _, total := range totals {
+			list = append(list, []string{
+				total.Key,
+				strconv.Itoa(total.Messages),
+				strconv.Itoa(total.InputTokens),
+				strconv.Itoa(total.CachedTokens),
+				strconv.Itoa(total.OutputTokens),
+				strconv.Itoa(total.ReasoningTokens),
+				strconv.Itoa(total.Total()),
+			})
+		}
+		message.Text += "\n## " + item.title + "\n"
+		if len(list) == 0 {
+			message.Text += "Sin consumo registrado\n"
+			continue
+		}
+		message.Text += toolspkg.TableStatGeneral(
+			[]string{"Clave", "Msgs", "Entrada", "Cache", "Salida", "Razon.", "Total"},
+			list,
+		)
+	}
+	if !found {
+		message.Text = "Uso: `/usage` [thread|agent|model|day]"
+	}
+
+	c.bus.Publish(eventpkg.EvtMessage, message)
+
+	// show command //
+	return true
+}
@@ -7,9 +7,132 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
+// ProviderConfig define un endpoint de modelo de lenguaje
+type ProviderConfig struct {
+	Name  string `yaml:"name"`
+	Kind  string `yaml:"kind"` // "responses" | "chat" | "ollama"
+	Url   string `yaml:"url"`
+	Key   string `yaml:"key"`
+	Model string `yaml:"model"`
+	// Grabación y reproducción sin red (pruebas deterministas) //
+	Mode     string `yaml:"mode"`     // "" = en vivo | "record" | "replay"
+	Fixtures string `yaml:"fixtures"` // archivo JSON de pares petición/respuesta
+}
+
+// AgentConfig declara un agente que se registra en el Manager al arrancar
+type AgentConfig struct {
+	Name          string   `yaml:"name"`
+	Kind          string   `yaml:"kind"` // "llm" (por defecto) | "echo" | "process"
+	Provider      string   `yaml:"provider"`
+	Model         string   `yaml:"model"` // vacío = modelo del proveedor
+	Instructions  string   `yaml:"instructions"`
+	Temperature   *float64 `yaml:"temperature"`
+	Context       string   `yaml:"context"`         // "conversation" (estado remoto) | "history" (historial local)
+	AutoRestart   *bool    `yaml:"auto_restart"`    // vacío = true
+	MinBackoff    string   `yaml:"min_backoff"`     // duración, p. ej. "100ms"
+	MaxBackoff    string   `yaml:"max_backoff"`     // duración, p. ej. "5s"
+	Group         string   `yaml:"group"`           // grupo de supervisión; vacío = el agente va solo
+	Strategy      string   `yaml:"strategy"`        // "one_for_one" (por defecto) | "one_for_all" | "rest_for_one"
+	MaxRestarts   int      `yaml:"max_restarts"`    // reinicios permitidos en restart_window antes de quedar failed; 0 = 5, -1 = sin límite
+	RestartWindow string   `yaml:"restart_window"`  // duración; vacío = "1m"
+	StableAfter   string   `yaml:"stable_after"`    // tiempo en marcha que reinicia el backoff; vacío = "30s"
+	StopTimeout   string   `yaml:"stop_timeout"`    // plazo para terminar la respuesta en curso y, tras cancelar, para salir; vacío = "5s"
+	Command       string   `yaml:"command"`         // kind "process": ejecutable del agente
+	Args          []string `yaml:"args"`            // kind "process": argumentos
+	Dir           string   `yaml:"dir"`             // kind "process": directorio de trabajo
+	Env           []string `yaml:"env"`             // kind "process": variables extra "KEY=VALUE"
+	Tools         []string `yaml:"tools"`           // kind "llm": herramientas que se anuncian al modelo
+	MaxToolRounds int      `yaml:"max_tool_rounds"` // kind "llm": vueltas de herramientas por respuesta; 0 = 8
+	CompactAt     int      `yaml:"compact_at"`      // kind "llm": tokens estimados del prompt que disparan el resumen; 0 = nunca
+	CompactKeep   int      `yaml:"compact_keep"`    // kind "llm": mensajes recientes que no se resumen; 0 = 6
+}
+
+// ToolsConfig limita lo que pueden hacer las herramientas de los agentes
+type ToolsConfig struct {
+	Workspace       string   `yaml:"workspace"`        // vacío = directorio actual
+	MaxFileSize     int64    `yaml:"max_file_size"`    // bytes que lee read_file; 0 = 64 KiB
+	AllowedCommands []string `yaml:"allowed_commands"` // ejecutables permitidos en run_command
+	CommandTimeout  string   `yaml:"command_timeout"`  // duración; vacío = "10s"
+}
+
+// AttachmentsConfig limita los archivos que se adjuntan con `/attach` o @ruta
+type AttachmentsConfig struct {
+	Workspace    string `yaml:"workspace"`      // vacío = directorio actual
+	MaxFileSize  int64  `yaml:"max_file_size"`  // bytes por archivo; 0 = 64 KiB
+	MaxTotalSize int64  `yaml:"max_total_size"` // bytes por mensaje; 0 = 256 KiB
+	MaxFiles     int    `yaml:"max_files"`      // archivos por mensaje; 0 = 20
+}
+
+// RetrievalConfig es el índice local de documentos que se consulta antes de
+// cada petición de los agentes "llm"; vacío dir = desactivado
+type RetrievalConfig struct {
+	Dir          string `yaml:"dir"`           // carpeta que se indexa (respeta .gitignore)
+	TopK         int    `yaml:"top_k"`         // fragmentos que se inyectan; 0 = 4
+	ChunkLines   int    `yaml:"chunk_lines"`   // líneas por fragmento; 0 = 40
+	ChunkOverlap int    `yaml:"chunk_overlap"` // líneas compartidas entre fragmentos; 0 = 8
+	MaxFileSize  int64  `yaml:"max_file_size"` // archivos mayores no se indexan; 0 = 256 KiB
+}
+
+// CacheConfig activa la caché de respuestas de los agentes "llm"
+type CacheConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	TTL     string `yaml:"ttl"` // duración; vacío = "24h"
+}
+
+// BudgetLimit es un tope de gasto; 0 = sin límite
+type BudgetLimit struct {
+	Tokens int     `yaml:"tokens"`
+	Cost   float64 `yaml:"cost"` // en la moneda de `prices`
+}
+
+// BudgetConfig son los límites iniciales; `/budget` los ajusta en la base de datos
+type BudgetConfig struct {
+	Thread BudgetLimit `yaml:"thread"`
+	Day    BudgetLimit `yaml:"day"`
+}
+
+// PriceConfig es el precio por millón de tokens de un modelo (o prefijo de modelo)
+type PriceConfig struct {
+	Model  string  `yaml:"model"`
+	Input  float64 `yaml:"input"`
+	Cached float64 `yaml:"cached"` // vacío = precio de entrada
+	Output float64 `yaml:"output"`
+}
+
+// PersonaConfig son instrucciones reutilizables y, opcionalmente, su modelo
+type PersonaConfig struct {
+	Name         string `yaml:"name"`
+	Instructions string `yaml:"instructions"`
+	Model        string `yaml:"model"` // vacío = modelo del agente
+}
+
+// RoutingRule dirige a `agents` los mensajes que cumplen `match` (regexp)
+type RoutingRule struct {
+	Match  string   `yaml:"match"`
+	Agents []string `yaml:"agents"`
+}
+
+// RoutingConfig decide qué agentes escuchan cuando nadie es mencionado
+type RoutingConfig struct {
+	Default []string      `yaml:"default"` // vacío = todos los agentes
+	Rules   []RoutingRule `yaml:"rules"`
+}
+
 type Config struct {
 	Config struct {
-		Messages struct {
+		Providers   []ProviderConfig  `yaml:"providers"`
+		Agents      []AgentConfig     `yaml:"agents"`
+		Routing     RoutingConfig     `yaml:"routing"`
+		Tools       ToolsConfig       `yaml:"tools"`
+		Attachments AttachmentsConfig `yaml:"attachments"`
+		Retrieval   RetrievalConfig   `yaml:"retrieval"`
+		Cache       CacheConfig       `yaml:"cache"`
+		Budgets     BudgetConfig      `yaml:"budgets"`
+		Prices      []PriceConfig     `yaml:"prices"`
+		Personas    []PersonaConfig   `yaml:"personas"`
+		// persona con la que empiezan los threads nuevos; vacío = ninguna
+		DefaultPersona string `yaml:"default_persona"`
+		Messages       struct {
 			Commands struct {
 				Title      string `yaml:"title"`
 				Collection []struct {
@@ -32,18 +155,60 @@ func GetEnv() {
 	}
 }
 
+const ConfigPath = "src/config.yaml"
+
 func LoadConfig() *Config {
 	GetEnv()
 
-	data, err := os.ReadFile("src/config.yaml")
+	cfg, err := ReadConfig(ConfigPath)
 	if err != nil {
 		panic(err)
 	}
 
+	return cfg
+}
+
+// ReadConfig lee y decodifica el archivo de configuración
+func ReadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
 	var cfg Config
 	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		panic(err)
+		return nil, err
+	}
+
+	return &cfg, nil
+}
+
+// Agent busca la configuración de un agente por nombre
+func (c *Config) Agent(name string) (AgentConfig, bool) {
+	for _, agent := range c.Config.Agents {
+		if agent.Name == name {
+			return agent, true
+		}
 	}
+	return AgentConfig{}, false
+}
 
-	return &cfg
+// Persona busca una persona por nombre
+func (c *Config) Persona(name string) (PersonaConfig, bool) {
+	for _, persona := range c.Config.Personas {
+		if persona.Name == name {
+			return persona, true
+		}
+	}
+	return PersonaConfig{}, false
+}
+
+// Provider busca la configuración de un proveedor por nombre
+func (c *Config) Provider(name string) (ProviderConfig, bool) {
+	for _, provider := range c.Config.Providers {
+		if provider.Name == name {
+			return provider, true
+		}
+	}
+	return ProviderConfig{}, false
 }
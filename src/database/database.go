@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"fmt"
 	"log/slog"
+	"slices"
+	"strings"
 	"time"
 
 	_ "modernc.org/sqlite"
@@ -14,6 +16,14 @@ import (
 
 type MessageModel = modelpkg.MessageModel
 type ThreadModel = modelpkg.ThreadModel
+type UsageModel = modelpkg.UsageModel
+type UsageTotal = modelpkg.UsageTotal
+type BudgetModel = modelpkg.BudgetModel
+type AttachmentModel = modelpkg.AttachmentModel
+type ChunkModel = modelpkg.ChunkModel
+type IndexStatusModel = modelpkg.IndexStatusModel
+type CacheStatsModel = modelpkg.CacheStatsModel
+type AgentRecordModel = modelpkg.AgentRecordModel
 
 type Database struct {
 	logger *slog.Logger
@@ -58,22 +68,214 @@ func (db *Database) Migration() error {
 
 			FOREIGN KEY (thread_id) REFERENCES threads(id) ON DELETE CASCADE
 		);
+
+		CREATE TABLE IF NOT EXISTS thread_conversations (
+			thread_id TEXT NOT NULL,
+			agent TEXT NOT NULL,
+			conversation TEXT NOT NULL,
+			updated_at TEXT NOT NULL,
+
+			PRIMARY KEY (thread_id, agent),
+			FOREIGN KEY (thread_id) REFERENCES threads(id) ON DELETE CASCADE
+		);
+
+		CREATE TABLE IF NOT EXISTS settings (
+			scope TEXT NOT NULL,
+			key TEXT NOT NULL,
+			value TEXT NOT NULL,
+			updated_at TEXT NOT NULL,
+
+			PRIMARY KEY (scope, key)
+		);
+
+		CREATE TABLE IF NOT EXISTS budgets (
+			scope TEXT PRIMARY KEY NOT NULL,
+			tokens INTEGER NOT NULL DEFAULT 0,
+			cost REAL NOT NULL DEFAULT 0,
+			updated_at TEXT NOT NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS attachments (
+			id TEXT PRIMARY KEY NOT NULL,
+			message_id TEXT NOT NULL,
+			path TEXT NOT NULL,
+			size INTEGER NOT NULL,
+			content TEXT NOT NULL,
+			created_at TEXT NOT NULL,
+
+			FOREIGN KEY (message_id) REFERENCES messages(id) ON DELETE CASCADE
+		);
+		CREATE INDEX IF NOT EXISTS attachments_message_id ON attachments(message_id);
+
+		CREATE VIRTUAL TABLE IF NOT EXISTS chunks USING fts5(
+			path,
+			start_line UNINDEXED,
+			end_line UNINDEXED,
+			content,
+			tokenize = 'unicode61 remove_diacritics 2'
+		);
+
+		CREATE TABLE IF NOT EXISTS response_cache (
+			key TEXT PRIMARY KEY NOT NULL,
+			model TEXT NOT NULL,
+			response TEXT NOT NULL,
+			hits INTEGER NOT NULL DEFAULT 0,
+			created_at TEXT NOT NULL,
+			expires_at TEXT NOT NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS agents (
+			name TEXT PRIMARY KEY NOT NULL,
+			config TEXT NOT NULL DEFAULT '',
+			enabled INTEGER NOT NULL DEFAULT 1,
+			updated_at TEXT NOT NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS index_files (
+			path TEXT PRIMARY KEY NOT NULL,
+			size INTEGER NOT NULL,
+			chunks INTEGER NOT NULL,
+			indexed_at TEXT NOT NULL
+		);
 	`)
 	if err != nil {
 		db.logger.Error("Error Database [Migration]", "msg", err.Error())
 		return err
 	}
+
+	// Columnas añadidas después de la primera versión del esquema //
+	branched, err := db.hasColumn("messages", "parent_id")
+	if err != nil {
+		db.logger.Error("Error Database [Migration]", "msg", err.Error())
+		return err
+	}
+	columns := []struct{ table, column, definition string }{
+		{"messages", "reply_to", "TEXT NOT NULL DEFAULT ''"},
+		{"messages", "addressed_to", "TEXT NOT NULL DEFAULT ''"},
+		{"threads", "default_agent", "TEXT NOT NULL DEFAULT ''"},
+		{"messages", "model", "TEXT NOT NULL DEFAULT ''"},
+		{"messages", "input_tokens", "INTEGER NOT NULL DEFAULT 0"},
+		{"messages", "cached_tokens", "INTEGER NOT NULL DEFAULT 0"},
+		{"messages", "output_tokens", "INTEGER NOT NULL DEFAULT 0"},
+		{"messages", "reasoning_tokens", "INTEGER NOT NULL DEFAULT 0"},
+		{"messages", "parent_id", "TEXT NOT NULL DEFAULT ''"},
+		{"threads", "head_id", "TEXT NOT NULL DEFAULT ''"},
+		{"threads", "instructions", "TEXT NOT NULL DEFAULT ''"},
+		{"threads", "persona", "TEXT NOT NULL DEFAULT ''"},
+		{"messages", "cached", "INTEGER NOT NULL DEFAULT 0"},
+	}
+	for _, col := range columns {
+		if err := db.addColumn(col.table, col.column, col.definition); err != nil {
+			db.logger.Error("Error Database [Migration]", "msg", err.Error())
+			return err
+		}
+	}
+	if !branched {
+		if err := db.backfillBranches(); err != nil {
+			db.logger.Error("Error Database [Migration] backfill branches", "msg", err.Error())
+			return err
+		}
+	}
 	return nil
 }
 
+// hasColumn indica si la tabla ya tiene la columna
+func (db *Database) hasColumn(table string, column string) (bool, error) {
+	rows, err := db.conn.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			ctype     string
+			notnull   int
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &ctype, &notnull, &dfltValue, &pk); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
+
+// addColumn añade la columna si la tabla aún no la tiene
+func (db *Database) addColumn(table string, column string, definition string) error {
+	exists, err := db.hasColumn(table, column)
+	if err != nil || exists {
+		return err
+	}
+
+	_, err = db.conn.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+// backfillBranches encadena los mensajes guardados antes de existir las ramas:
+// cada mensaje de la conversación cuelga del anterior y el último es la cabeza del hilo
+func (db *Database) backfillBranches() error {
+	rows, err := db.conn.Query(`
+		SELECT id, thread_id FROM messages
+			WHERE type IN (1, 3, 4)
+			ORDER BY thread_id, created_at ASC, rowid ASC
+	`)
+	if err != nil {
+		return err
+	}
+	type link struct{ id, threadId string }
+	var links []link
+	for rows.Next() {
+		var item link
+		if err := rows.Scan(&item.id, &item.threadId); err != nil {
+			rows.Close()
+			return err
+		}
+		links = append(links, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for idx, item := range links {
+		if idx > 0 && links[idx-1].threadId == item.threadId {
+			if _, err := tx.Exec("UPDATE messages SET parent_id = ? WHERE id = ?", links[idx-1].id, item.id); err != nil {
+				return err
+			}
+		}
+		if idx == len(links)-1 || links[idx+1].threadId != item.threadId {
+			if _, err := tx.Exec("UPDATE threads SET head_id = ? WHERE id = ?", item.id, item.threadId); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
 func (db *Database) CreateThread(thd ThreadModel) (*ThreadModel, error) {
 	thd.Id = toolspkg.GenerateUUID()
 
 	_, err := db.conn.Exec(`
-			INSERT INTO threads (id, name, created_at) VALUES (?, ?, ?)
+			INSERT INTO threads (id, name, default_agent, instructions, persona, created_at) VALUES (?, ?, ?, ?, ?, ?)
 		`,
 		thd.Id,
 		thd.Name,
+		thd.DefaultAgent,
+		thd.Instructions,
+		thd.Persona,
 		thd.CreatedAt.Format(time.RFC3339),
 	)
 	if err != nil {
@@ -86,7 +288,7 @@ func (db *Database) CreateThread(thd ThreadModel) (*ThreadModel, error) {
 
 func (db *Database) ListThreads() ([]ThreadModel, error) {
 	rows, err := db.conn.Query(`
-			SELECT id, name, created_at FROM threads ORDER BY created_at ASC
+			SELECT id, name, default_agent, head_id, instructions, persona, created_at FROM threads ORDER BY created_at ASC
 		`)
 	if err != nil {
 		db.logger.Error("Error Database [ListThreads]", "msg", err.Error())
@@ -99,7 +301,7 @@ func (db *Database) ListThreads() ([]ThreadModel, error) {
 		var thd ThreadModel
 		var createdAt string
 
-		if err := rows.Scan(&thd.Id, &thd.Name, &createdAt); err != nil {
+		if err := rows.Scan(&thd.Id, &thd.Name, &thd.DefaultAgent, &thd.HeadId, &thd.Instructions, &thd.Persona, &createdAt); err != nil {
 			db.logger.Error("Error Database [ListThreads]", "msg", err.Error())
 			return nil, err
 		}
@@ -121,11 +323,36 @@ func (db *Database) ListThreads() ([]ThreadModel, error) {
 	return threads, nil
 }
 
+func (db *Database) GetThread(id string) (*ThreadModel, error) {
+	var thd ThreadModel
+	var createdAt string
+
+	err := db.conn.QueryRow(`
+			SELECT id, name, default_agent, head_id, instructions, persona, created_at FROM threads WHERE id = ?
+		`, id).Scan(&thd.Id, &thd.Name, &thd.DefaultAgent, &thd.HeadId, &thd.Instructions, &thd.Persona, &createdAt)
+	if err != nil {
+		db.logger.Error("Error Database [GetThread]", "msg", err.Error())
+		return nil, err
+	}
+
+	parsedTime, err := time.Parse(time.RFC3339, createdAt)
+	if err != nil {
+		db.logger.Error("Error Database [GetThread] parsing time", "msg", err.Error())
+		return nil, err
+	}
+	thd.CreatedAt = parsedTime
+
+	return &thd, nil
+}
+
 func (db *Database) UpdateThread(thd ThreadModel) error {
 	_, err := db.conn.Exec(`
-			UPDATE threads SET name = ? WHERE id = ?
+			UPDATE threads SET name = ?, default_agent = ?, instructions = ?, persona = ? WHERE id = ?
 		`,
 		thd.Name,
+		thd.DefaultAgent,
+		thd.Instructions,
+		thd.Persona,
 		thd.Id,
 	)
 	if err != nil {
@@ -138,9 +365,11 @@ func (db *Database) UpdateThread(thd ThreadModel) error {
 
 func (db *Database) DeleteThread(thd ThreadModel) error {
 	_, err := db.conn.Exec(`
-			DELETE FROM threads WHERE id = ?
+			DELETE FROM threads WHERE id = ?;
+			DELETE FROM settings WHERE scope = ?;
 		`,
 		thd.Id,
+		thd.Id,
 	)
 	if err != nil {
 		db.logger.Error("Error Database [DeleteThread]", "msg", err.Error())
@@ -151,9 +380,23 @@ func (db *Database) DeleteThread(thd ThreadModel) error {
 }
 
 func (db *Database) CreateMessage(msg MessageModel) (string, error) {
-	id := toolspkg.GenerateUUID()
+	id := msg.Id
+	if id == "" {
+		id = toolspkg.GenerateUUID()
+	}
+	usage := UsageModel{}
+	if msg.Usage != nil {
+		usage = *msg.Usage
+	}
 
-	_, err := db.conn.Exec(`
+	tx, err := db.conn.Begin()
+	if err != nil {
+		db.logger.Error("Error Database [CreateMessage]", "msg", err.Error())
+		return "", err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
 			INSERT INTO messages (
 				id,
 				type,
@@ -161,8 +404,17 @@ func (db *Database) CreateMessage(msg MessageModel) (string, error) {
 				written_by,
 				text,
 				thread_id,
+				reply_to,
+				addressed_to,
+				parent_id,
+				model,
+				input_tokens,
+				cached_tokens,
+				output_tokens,
+				reasoning_tokens,
+				cached,
 				created_at
-			) VALUES (?, ?, ?, ?, ?, ?, ?)
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 		`,
 		id,
 		msg.Type,
@@ -170,32 +422,162 @@ func (db *Database) CreateMessage(msg MessageModel) (string, error) {
 		msg.WrittenBy,
 		msg.Text,
 		msg.ThreadId,
+		msg.ReplyTo,
+		strings.Join(msg.To, ","),
+		msg.ParentId,
+		usage.Model,
+		usage.InputTokens,
+		usage.CachedTokens,
+		usage.OutputTokens,
+		usage.ReasoningTokens,
+		msg.Cached,
 		msg.CreatedAt.Format(time.RFC3339),
 	)
 	if err != nil {
 		db.logger.Error("Error Database [CreateMessage]", "msg", err.Error())
 		return "", err
 	}
+
+	// Adjuntos del mensaje //
+	for _, attachment := range msg.Attachments {
+		attachmentId := attachment.Id
+		if attachmentId == "" {
+			attachmentId = toolspkg.GenerateUUID()
+		}
+		_, err = tx.Exec(`
+				INSERT INTO attachments (id, message_id, path, size, content, created_at)
+				VALUES (?, ?, ?, ?, ?, ?)
+			`,
+			attachmentId,
+			id,
+			attachment.Path,
+			attachment.Size,
+			attachment.Content,
+			msg.CreatedAt.Format(time.RFC3339),
+		)
+		if err != nil {
+			db.logger.Error("Error Database [CreateMessage] attachments", "msg", err.Error())
+			return "", err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		db.logger.Error("Error Database [CreateMessage]", "msg", err.Error())
+		return "", err
+	}
 	return id, nil
 }
 
+// ListMessageByThreadId devuelve la rama activa del hilo (la que termina en su cabeza);
+// con all incluye también los mensajes de sistema y comandos
 func (db *Database) ListMessageByThreadId(
 	threadId string,
 	all bool,
 ) ([]MessageModel, error) {
-	full := ""
+	var headId string
+	err := db.conn.QueryRow("SELECT head_id FROM threads WHERE id = ?", threadId).Scan(&headId)
+	if err != nil && err != sql.ErrNoRows {
+		db.logger.Error("Error Database [ListMessageByThreadId]", "msg", err.Error())
+		return nil, err
+	}
 
+	return db.ListBranch(threadId, headId, all)
+}
+
+// ListBranch devuelve los mensajes desde la raíz hasta leafId siguiendo parent_id
+func (db *Database) ListBranch(threadId string, leafId string, all bool) ([]MessageModel, error) {
+	messages, err := db.listThreadMessages(threadId)
+	if err != nil {
+		return nil, err
+	}
+
+	byId := make(map[string]MessageModel, len(messages))
+	for _, msg := range messages {
+		byId[msg.Id] = msg
+	}
+
+	var branch []MessageModel
+	for id := leafId; id != ""; {
+		msg, ok := byId[id]
+		if !ok {
+			break
+		}
+		branch = append(branch, msg)
+		id = msg.ParentId
+		// protege de ciclos //
+		delete(byId, msg.Id)
+	}
+	slices.Reverse(branch)
 	if !all {
-		full = "AND type = 1"
+		return branch, nil
 	}
 
-	rows, err := db.conn.Query(fmt.Sprintf(`
+	// Intercala por fecha los mensajes que no forman parte del árbol //
+	var merged []MessageModel
+	idx := 0
+	for _, msg := range messages {
+		if msg.InTree() {
+			continue
+		}
+		for idx < len(branch) && !branch[idx].CreatedAt.After(msg.CreatedAt) {
+			merged = append(merged, branch[idx])
+			idx++
+		}
+		merged = append(merged, msg)
+	}
+	return append(merged, branch[idx:]...), nil
+}
+
+// Children devuelve, por cada padre, los ids de sus hijos en orden de creación
+func (db *Database) Children(threadId string) (map[string][]string, error) {
+	messages, err := db.listThreadMessages(threadId)
+	if err != nil {
+		return nil, err
+	}
+
+	children := map[string][]string{}
+	for _, msg := range messages {
+		if msg.InTree() {
+			children[msg.ParentId] = append(children[msg.ParentId], msg.Id)
+		}
+	}
+	return children, nil
+}
+
+// SetThreadHead cambia la rama activa del hilo
+func (db *Database) SetThreadHead(threadId string, headId string) error {
+	_, err := db.conn.Exec(`
+			UPDATE threads SET head_id = ? WHERE id = ?
+		`,
+		headId,
+		threadId,
+	)
+	if err != nil {
+		db.logger.Error("Error Database [SetThreadHead]", "msg", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (db *Database) ThreadHead(threadId string) (string, error) {
+	var headId string
+	err := db.conn.QueryRow("SELECT head_id FROM threads WHERE id = ?", threadId).Scan(&headId)
+	if err != nil && err != sql.ErrNoRows {
+		db.logger.Error("Error Database [ThreadHead]", "msg", err.Error())
+		return "", err
+	}
+	return headId, nil
+}
+
+func (db *Database) listThreadMessages(threadId string) ([]MessageModel, error) {
+	rows, err := db.conn.Query(`
 		SELECT
-			id, type, source, written_by, text, thread_id, created_at
+			id, type, source, written_by, text, thread_id, reply_to, addressed_to, parent_id,
+			model, input_tokens, cached_tokens, output_tokens, reasoning_tokens, cached, created_at
 			FROM messages
-			WHERE thread_id = ? %s
-			ORDER BY created_at ASC
-		`, full), threadId)
+			WHERE thread_id = ?
+			ORDER BY created_at ASC, rowid ASC
+		`, threadId)
 	if err != nil {
 		db.logger.Error("Error Database [ListMessageByThreadId]", "msg", err.Error())
 		return nil, err
@@ -205,9 +587,14 @@ func (db *Database) ListMessageByThreadId(
 	var messages []MessageModel
 	for rows.Next() {
 		var msg MessageModel
+		var addressedTo string
+		var usage UsageModel
 		var createdAt string
 
-		if err := rows.Scan(&msg.Id, &msg.Type, &msg.Source, &msg.WrittenBy, &msg.Text, &msg.ThreadId, &createdAt); err != nil {
+		if err := rows.Scan(
+			&msg.Id, &msg.Type, &msg.Source, &msg.WrittenBy, &msg.Text, &msg.ThreadId, &msg.ReplyTo, &addressedTo, &msg.ParentId,
+			&usage.Model, &usage.InputTokens, &usage.CachedTokens, &usage.OutputTokens, &usage.ReasoningTokens, &msg.Cached, &createdAt,
+		); err != nil {
 			db.logger.Error("Error Database [ListMessageByThreadId]", "msg", err.Error())
 			return nil, err
 		}
@@ -218,6 +605,12 @@ func (db *Database) ListMessageByThreadId(
 			return nil, err
 		}
 		msg.CreatedAt = parsedTime
+		if addressedTo != "" {
+			msg.To = strings.Split(addressedTo, ",")
+		}
+		if usage.Total() > 0 {
+			msg.Usage = &usage
+		}
 
 		messages = append(messages, msg)
 	}
@@ -226,9 +619,587 @@ func (db *Database) ListMessageByThreadId(
 		return nil, err
 	}
 
+	attachments, err := db.listThreadAttachments(threadId)
+	if err != nil {
+		return nil, err
+	}
+	for idx := range messages {
+		messages[idx].Attachments = attachments[messages[idx].Id]
+	}
+
 	return messages, nil
 }
 
+// listThreadAttachments devuelve los adjuntos del hilo agrupados por mensaje
+func (db *Database) listThreadAttachments(threadId string) (map[string][]AttachmentModel, error) {
+	rows, err := db.conn.Query(`
+		SELECT a.id, a.message_id, a.path, a.size, a.content
+			FROM attachments a
+			JOIN messages m ON m.id = a.message_id
+			WHERE m.thread_id = ?
+			ORDER BY a.rowid ASC
+		`, threadId)
+	if err != nil {
+		db.logger.Error("Error Database [listThreadAttachments]", "msg", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	attachments := map[string][]AttachmentModel{}
+	for rows.Next() {
+		var attachment AttachmentModel
+		if err := rows.Scan(
+			&attachment.Id, &attachment.MessageId, &attachment.Path, &attachment.Size, &attachment.Content,
+		); err != nil {
+			db.logger.Error("Error Database [listThreadAttachments]", "msg", err.Error())
+			return nil, err
+		}
+		attachments[attachment.MessageId] = append(attachments[attachment.MessageId], attachment)
+	}
+	if err := rows.Err(); err != nil {
+		db.logger.Error("Error Database [listThreadAttachments]", "msg", err.Error())
+		return nil, err
+	}
+	return attachments, nil
+}
+
+// ListSettings devuelve los ajustes de un ámbito ("global" o id de hilo)
+func (db *Database) ListSettings(scope string) (map[string]string, error) {
+	rows, err := db.conn.Query(`
+			SELECT key, value FROM settings WHERE scope = ?
+		`, scope)
+	if err != nil {
+		db.logger.Error("Error Database [ListSettings]", "msg", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	settings := map[string]string{}
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			db.logger.Error("Error Database [ListSettings]", "msg", err.Error())
+			return nil, err
+		}
+		settings[key] = value
+	}
+	if err := rows.Err(); err != nil {
+		db.logger.Error("Error Database [ListSettings]", "msg", err.Error())
+		return nil, err
+	}
+
+	return settings, nil
+}
+
+// SetSetting guarda un ajuste; con value vacío lo elimina
+func (db *Database) SetSetting(scope string, key string, value string) error {
+	var err error
+	if value == "" {
+		_, err = db.conn.Exec("DELETE FROM settings WHERE scope = ? AND key = ?", scope, key)
+	} else {
+		_, err = db.conn.Exec(`
+				INSERT INTO settings (scope, key, value, updated_at) VALUES (?, ?, ?, ?)
+				ON CONFLICT(scope, key) DO UPDATE SET
+					value = excluded.value,
+					updated_at = excluded.updated_at
+			`,
+			scope,
+			key,
+			value,
+			time.Now().Format(time.RFC3339),
+		)
+	}
+	if err != nil {
+		db.logger.Error("Error Database [SetSetting]", "msg", err.Error())
+		return err
+	}
+	return nil
+}
+
+// ClearConversations olvida el estado remoto de todos los agentes del hilo;
+// se usa al cambiar de rama, porque ese estado pertenece a la rama anterior
+func (db *Database) ClearConversations(threadId string) error {
+	_, err := db.conn.Exec("DELETE FROM thread_conversations WHERE thread_id = ?", threadId)
+	if err != nil {
+		db.logger.Error("Error Database [ClearConversations]", "msg", err.Error())
+		return err
+	}
+	return nil
+}
+
+func (db *Database) GetConversation(threadId string, agent string) (string, error) {
+	var conversation string
+
+	err := db.conn.QueryRow(`
+			SELECT conversation FROM thread_conversations WHERE thread_id = ? AND agent = ?
+		`,
+		threadId,
+		agent,
+	).Scan(&conversation)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		db.logger.Error("Error Database [GetConversation]", "msg", err.Error())
+		return "", err
+	}
+
+	return conversation, nil
+}
+
+func (db *Database) SetConversation(threadId string, agent string, conversation string) error {
+	_, err := db.conn.Exec(`
+			INSERT INTO thread_conversations (thread_id, agent, conversation, updated_at)
+			VALUES (?, ?, ?, ?)
+			ON CONFLICT (thread_id, agent) DO UPDATE SET
+				conversation = excluded.conversation,
+				updated_at = excluded.updated_at
+		`,
+		threadId,
+		agent,
+		conversation,
+		time.Now().Format(time.RFC3339),
+	)
+	if err != nil {
+		db.logger.Error("Error Database [SetConversation]", "msg", err.Error())
+		return err
+	}
+
+	return nil
+}
+
+// ThreadUsage suma los tokens consumidos en un hilo
+func (db *Database) ThreadUsage(threadId string) (UsageModel, error) {
+	var usage UsageModel
+	err := db.conn.QueryRow(`
+		SELECT
+			COALESCE(SUM(input_tokens), 0),
+			COALESCE(SUM(cached_tokens), 0),
+			COALESCE(SUM(output_tokens), 0),
+			COALESCE(SUM(reasoning_tokens), 0)
+			FROM messages
+			WHERE thread_id = ?
+		`, threadId).Scan(&usage.InputTokens, &usage.CachedTokens, &usage.OutputTokens, &usage.ReasoningTokens)
+	if err != nil {
+		db.logger.Error("Error Database [ThreadUsage]", "msg", err.Error())
+		return usage, err
+	}
+	return usage, nil
+}
+
+// UsageTotals agrupa el consumo por "thread", "agent", "model" o "day"
+func (db *Database) UsageTotals(group string) ([]UsageTotal, error) {
+	var key string
+	switch group {
+	case "thread":
+		key = "COALESCE(t.name, m.thread_id)"
+	case "agent":
+		key = "m.written_by"
+	case "model":
+		key = "m.model"
+	case "day":
+		key = "substr(m.created_at, 1, 10)"
+	default:
+		return nil, fmt.Errorf("usage group %q not supported", group)
+	}
+
+	rows, err := db.conn.Query(fmt.Sprintf(`
+		SELECT
+			%s AS key,
+			COUNT(*),
+			SUM(m.input_tokens),
+			SUM(m.cached_tokens),
+			SUM(m.output_tokens),
+			SUM(m.reasoning_tokens)
+			FROM messages m
+			LEFT JOIN threads t ON t.id = m.thread_id
+			WHERE m.input_tokens + m.output_tokens > 0
+			GROUP BY key
+			ORDER BY key ASC
+		`, key))
+	if err != nil {
+		db.logger.Error("Error Database [UsageTotals]", "msg", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	var totals []UsageTotal
+	for rows.Next() {
+		var total UsageTotal
+		if err := rows.Scan(
+			&total.Key, &total.Messages,
+			&total.InputTokens, &total.CachedTokens, &total.OutputTokens, &total.ReasoningTokens,
+		); err != nil {
+			db.logger.Error("Error Database [UsageTotals]", "msg", err.Error())
+			return nil, err
+		}
+		totals = append(totals, total)
+	}
+	if err := rows.Err(); err != nil {
+		db.logger.Error("Error Database [UsageTotals]", "msg", err.Error())
+		return nil, err
+	}
+
+	return totals, nil
+}
+
+// UsageByModel suma el consumo por modelo de un hilo y/o un día ("2006-01-02");
+// los filtros vacíos no se aplican
+func (db *Database) UsageByModel(threadId string, day string) ([]UsageTotal, error) {
+	rows, err := db.conn.Query(`
+		SELECT
+			model,
+			COUNT(*),
+			SUM(input_tokens),
+			SUM(cached_tokens),
+			SUM(output_tokens),
+			SUM(reasoning_tokens)
+			FROM messages
+			WHERE input_tokens + output_tokens > 0
+				AND (? = '' OR thread_id = ?)
+				AND (? = '' OR substr(created_at, 1, 10) = ?)
+			GROUP BY model
+		`, threadId, threadId, day, day)
+	if err != nil {
+		db.logger.Error("Error Database [UsageByModel]", "msg", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	var totals []UsageTotal
+	for rows.Next() {
+		var total UsageTotal
+		if err := rows.Scan(
+			&total.Key, &total.Messages,
+			&total.InputTokens, &total.CachedTokens, &total.OutputTokens, &total.ReasoningTokens,
+		); err != nil {
+			db.logger.Error("Error Database [UsageByModel]", "msg", err.Error())
+			return nil, err
+		}
+		total.Model = total.Key
+		totals = append(totals, total)
+	}
+	if err := rows.Err(); err != nil {
+		db.logger.Error("Error Database [UsageByModel]", "msg", err.Error())
+		return nil, err
+	}
+
+	return totals, nil
+}
+
+func (db *Database) ListBudgets() ([]BudgetModel, error) {
+	rows, err := db.conn.Query(`
+			SELECT scope, tokens, cost FROM budgets ORDER BY scope ASC
+		`)
+	if err != nil {
+		db.logger.Error("Error Database [ListBudgets]", "msg", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	var budgets []BudgetModel
+	for rows.Next() {
+		var budget BudgetModel
+		if err := rows.Scan(&budget.Scope, &budget.Tokens, &budget.Cost); err != nil {
+			db.logger.Error("Error Database [ListBudgets]", "msg", err.Error())
+			return nil, err
+		}
+		budgets = append(budgets, budget)
+	}
+	if err := rows.Err(); err != nil {
+		db.logger.Error("Error Database [ListBudgets]", "msg", err.Error())
+		return nil, err
+	}
+
+	return budgets, nil
+}
+
+// SetBudget guarda (o reemplaza) el límite de un ámbito
+func (db *Database) SetBudget(budget BudgetModel) error {
+	_, err := db.conn.Exec(`
+			INSERT INTO budgets (scope, tokens, cost, updated_at) VALUES (?, ?, ?, ?)
+			ON CONFLICT(scope) DO UPDATE SET
+				tokens = excluded.tokens,
+				cost = excluded.cost,
+				updated_at = excluded.updated_at
+		`,
+		budget.Scope,
+		budget.Tokens,
+		budget.Cost,
+		time.Now().Format(time.RFC3339),
+	)
+	if err != nil {
+		db.logger.Error("Error Database [SetBudget]", "msg", err.Error())
+		return err
+	}
+	return nil
+}
+
+// ListAgentRecords devuelve los agentes persistidos, por nombre
+func (db *Database) ListAgentRecords() ([]AgentRecordModel, error) {
+	rows, err := db.conn.Query(`
+			SELECT name, config, enabled FROM agents ORDER BY name ASC
+		`)
+	if err != nil {
+		db.logger.Error("Error Database [ListAgentRecords]", "msg", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []AgentRecordModel
+	for rows.Next() {
+		var record AgentRecordModel
+		if err := rows.Scan(&record.Name, &record.Config, &record.Enabled); err != nil {
+			db.logger.Error("Error Database [ListAgentRecords]", "msg", err.Error())
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		db.logger.Error("Error Database [ListAgentRecords]", "msg", err.Error())
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// SaveAgentRecord guarda (o reemplaza) un agente creado con `/agent add`
+func (db *Database) SaveAgentRecord(record AgentRecordModel) error {
+	_, err := db.conn.Exec(`
+			INSERT INTO agents (name, config, enabled, updated_at) VALUES (?, ?, ?, ?)
+			ON CONFLICT(name) DO UPDATE SET
+				config = excluded.config,
+				enabled = excluded.enabled,
+				updated_at = excluded.updated_at
+		`,
+		record.Name,
+		record.Config,
+		record.Enabled,
+		time.Now().Format(time.RFC3339),
+	)
+	if err != nil {
+		db.logger.Error("Error Database [SaveAgentRecord]", "msg", err.Error())
+		return err
+	}
+	return nil
+}
+
+// SetAgentEnabled guarda la habilitación de un agente sin tocar su declaración
+func (db *Database) SetAgentEnabled(name string, enabled bool) error {
+	_, err := db.conn.Exec(`
+			INSERT INTO agents (name, enabled, updated_at) VALUES (?, ?, ?)
+			ON CONFLICT(name) DO UPDATE SET
+				enabled = excluded.enabled,
+				updated_at = excluded.updated_at
+		`,
+		name,
+		enabled,
+		time.Now().Format(time.RFC3339),
+	)
+	if err != nil {
+		db.logger.Error("Error Database [SetAgentEnabled]", "msg", err.Error())
+		return err
+	}
+	return nil
+}
+
+// DeleteAgentRecord olvida un agente persistido
+func (db *Database) DeleteAgentRecord(name string) error {
+	_, err := db.conn.Exec("DELETE FROM agents WHERE name = ?", name)
+	if err != nil {
+		db.logger.Error("Error Database [DeleteAgentRecord]", "msg", err.Error())
+		return err
+	}
+	return nil
+}
+
 func (db *Database) Close() error {
 	return db.conn.Close()
 }
+
+// ReplaceIndex sustituye el índice local completo por los fragmentos dados;
+// sizes es el tamaño de cada archivo indexado
+func (db *Database) ReplaceIndex(sizes map[string]int64, chunks []ChunkModel) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		db.logger.Error("Error Database [ReplaceIndex]", "msg", err.Error())
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+			DELETE FROM chunks;
+			DELETE FROM index_files;
+		`); err != nil {
+		db.logger.Error("Error Database [ReplaceIndex]", "msg", err.Error())
+		return err
+	}
+
+	counts := map[string]int{}
+	for _, chunk := range chunks {
+		_, err := tx.Exec(`
+				INSERT INTO chunks (path, start_line, end_line, content) VALUES (?, ?, ?, ?)
+			`,
+			chunk.Path,
+			chunk.StartLine,
+			chunk.EndLine,
+			chunk.Content,
+		)
+		if err != nil {
+			db.logger.Error("Error Database [ReplaceIndex] chunks", "msg", err.Error())
+			return err
+		}
+		counts[chunk.Path]++
+	}
+
+	now := time.Now().Format(time.RFC3339)
+	for path, size := range sizes {
+		_, err := tx.Exec(`
+				INSERT INTO index_files (path, size, chunks, indexed_at) VALUES (?, ?, ?, ?)
+			`,
+			path,
+			size,
+			counts[path],
+			now,
+		)
+		if err != nil {
+			db.logger.Error("Error Database [ReplaceIndex] files", "msg", err.Error())
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		db.logger.Error("Error Database [ReplaceIndex]", "msg", err.Error())
+		return err
+	}
+	return nil
+}
+
+// SearchChunks devuelve los fragmentos que cumplen la expresión FTS5 ordenados por bm25
+func (db *Database) SearchChunks(query string, limit int) ([]ChunkModel, error) {
+	rows, err := db.conn.Query(`
+		SELECT path, start_line, end_line, content, bm25(chunks)
+			FROM chunks
+			WHERE chunks MATCH ?
+			ORDER BY bm25(chunks)
+			LIMIT ?
+		`, query, limit)
+	if err != nil {
+		db.logger.Error("Error Database [SearchChunks]", "msg", err.Error())
+		return nil, err
+	}
+	defer rows.Close()
+
+	var chunks []ChunkModel
+	for rows.Next() {
+		var chunk ChunkModel
+		if err := rows.Scan(&chunk.Path, &chunk.StartLine, &chunk.EndLine, &chunk.Content, &chunk.Score); err != nil {
+			db.logger.Error("Error Database [SearchChunks]", "msg", err.Error())
+			return nil, err
+		}
+		chunks = append(chunks, chunk)
+	}
+	if err := rows.Err(); err != nil {
+		db.logger.Error("Error Database [SearchChunks]", "msg", err.Error())
+		return nil, err
+	}
+	return chunks, nil
+}
+
+// IndexStatus cuenta los archivos y fragmentos del índice local
+func (db *Database) IndexStatus() (IndexStatusModel, error) {
+	var status IndexStatusModel
+	var indexedAt sql.NullString
+	err := db.conn.QueryRow(`
+			SELECT count(*), coalesce(sum(chunks), 0), max(indexed_at) FROM index_files
+		`).Scan(&status.Files, &status.Chunks, &indexedAt)
+	if err != nil {
+		db.logger.Error("Error Database [IndexStatus]", "msg", err.Error())
+		return status, err
+	}
+	if indexedAt.Valid {
+		status.IndexedAt, _ = time.Parse(time.RFC3339, indexedAt.String)
+	}
+	return status, nil
+}
+
+// CachedResponse devuelve la respuesta guardada bajo key si no ha caducado
+// y cuenta el acierto
+func (db *Database) CachedResponse(key string) (string, bool, error) {
+	var response string
+	err := db.conn.QueryRow(`
+			UPDATE response_cache SET hits = hits + 1
+			WHERE key = ? AND expires_at > ?
+			RETURNING response
+		`,
+		key,
+		time.Now().UTC().Format(time.RFC3339),
+	).Scan(&response)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		db.logger.Error("Error Database [CachedResponse]", "msg", err.Error())
+		return "", false, err
+	}
+	return response, true, nil
+}
+
+// CacheResponse guarda la respuesta hasta expiresAt y purga las caducadas
+func (db *Database) CacheResponse(key string, model string, response string, expiresAt time.Time) error {
+	now := time.Now().UTC().Format(time.RFC3339)
+	if _, err := db.conn.Exec("DELETE FROM response_cache WHERE expires_at <= ?", now); err != nil {
+		db.logger.Error("Error Database [CacheResponse] purge", "msg", err.Error())
+		return err
+	}
+
+	_, err := db.conn.Exec(`
+			INSERT INTO response_cache (key, model, response, hits, created_at, expires_at)
+			VALUES (?, ?, ?, 0, ?, ?)
+			ON CONFLICT(key) DO UPDATE SET
+				model = excluded.model,
+				response = excluded.response,
+				created_at = excluded.created_at,
+				expires_at = excluded.expires_at
+		`,
+		key,
+		model,
+		response,
+		now,
+		expiresAt.UTC().Format(time.RFC3339),
+	)
+	if err != nil {
+		db.logger.Error("Error Database [CacheResponse]", "msg", err.Error())
+		return err
+	}
+	return nil
+}
+
+// ClearCache borra todas las respuestas guardadas y devuelve cuántas había
+func (db *Database) ClearCache() (int64, error) {
+	result, err := db.conn.Exec("DELETE FROM response_cache")
+	if err != nil {
+		db.logger.Error("Error Database [ClearCache]", "msg", err.Error())
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (db *Database) CacheStats() (CacheStatsModel, error) {
+	var stats CacheStatsModel
+	err := db.conn.QueryRow(`
+			SELECT
+				count(*),
+				coalesce(sum(expires_at <= ?), 0),
+				coalesce(sum(hits), 0),
+				coalesce(sum(length(response)), 0)
+			FROM response_cache
+		`,
+		time.Now().UTC().Format(time.RFC3339),
+	).Scan(&stats.Entries, &stats.Expired, &stats.Hits, &stats.Bytes)
+	if err != nil {
+		db.logger.Error("Error Database [CacheStats]", "msg", err.Error())
+		return stats, err
+	}
+	return stats, nil
+}
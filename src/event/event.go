@@ -9,6 +9,10 @@ type EventType int
 const (
 	EvtSystem EventType = iota
 	EvtMessage
+	EvtPartial  // mensaje parcial (streaming) que reemplaza al anterior con el mismo Id
+	EvtCancel   // cancela la petición en curso; Data = nombre del agente o "" (todos)
+	EvtApproval // pide o responde permiso para una herramienta; Data = ApprovalModel
+	EvtAgent    // cambio de estado de un agente en el Manager; Data = AgentEventModel
 )
 
 type EventModel struct {
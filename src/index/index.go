@@ -0,0 +1,206 @@
+package indexpkg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	attachpkg "main/src/attach"
+	configpkg "main/src/config"
+	databasepkg "main/src/database"
+	modelpkg "main/src/model"
+)
+
+type Database = databasepkg.Database
+type ChunkModel = modelpkg.ChunkModel
+type IndexStatusModel = modelpkg.IndexStatusModel
+
+const (
+	defaultTopK         = 4
+	defaultChunkLines   = 40
+	defaultChunkOverlap = 8
+	defaultMaxFileSize  = 256 * 1024
+	// términos de la pregunta que se usan en la búsqueda //
+	maxQueryTerms = 24
+)
+
+var ErrRebuilding = errors.New("index rebuild already in progress")
+
+var wordPattern = regexp.MustCompile(`[\p{L}\p{N}_]{3,}`)
+
+// stopwords son palabras demasiado comunes para distinguir fragmentos
+var stopwords = map[string]bool{
+	"que": true, "los": true, "las": true, "del": true, "por": true, "para": true,
+	"con": true, "una": true, "uno": true, "como": true, "esta": true,
+	"este": true, "esto": true, "qué": true, "cómo": true, "hay": true, "son": true,
+	"the": true, "and": true, "for": true, "with": true, "what": true, "how": true,
+	"does": true, "this": true, "that": true, "are": true, "from": true, "where": true,
+}
+
+// Index es el índice local de documentos (FTS5 con bm25) que se consulta
+// antes de cada petición de los agentes "llm"
+type Index struct {
+	db           *Database
+	Dir          string
+	TopK         int
+	ChunkLines   int
+	ChunkOverlap int
+	MaxFileSize  int64
+
+	rebuilding sync.Mutex
+}
+
+// NewIndex devuelve nil si no hay carpeta configurada
+func NewIndex(conf configpkg.RetrievalConfig, db *Database) (*Index, error) {
+	if conf.Dir == "" {
+		return nil, nil
+	}
+	dir, err := filepath.Abs(os.ExpandEnv(conf.Dir))
+	if err != nil {
+		return nil, err
+	}
+
+	ix := &Index{
+		db:           db,
+		Dir:          dir,
+		TopK:         conf.TopK,
+		ChunkLines:   conf.ChunkLines,
+		ChunkOverlap: conf.ChunkOverlap,
+		MaxFileSize:  conf.MaxFileSize,
+	}
+	if ix.TopK <= 0 {
+		ix.TopK = defaultTopK
+	}
+	if ix.ChunkLines <= 0 {
+		ix.ChunkLines = defaultChunkLines
+	}
+	if ix.ChunkOverlap <= 0 {
+		ix.ChunkOverlap = defaultChunkOverlap
+	}
+	if ix.ChunkOverlap >= ix.ChunkLines {
+		ix.ChunkOverlap = ix.ChunkLines / 4
+	}
+	if ix.MaxFileSize <= 0 {
+		ix.MaxFileSize = defaultMaxFileSize
+	}
+	return ix, nil
+}
+
+// Rebuild vuelve a indexar la carpeta completa; devuelve el nuevo estado y
+// cuántos archivos se omitieron (binarios o demasiado grandes)
+func (ix *Index) Rebuild() (IndexStatusModel, int, error) {
+	if !ix.rebuilding.TryLock() {
+		return IndexStatusModel{}, 0, ErrRebuilding
+	}
+	defer ix.rebuilding.Unlock()
+
+	if info, err := os.Stat(ix.Dir); err != nil || !info.IsDir() {
+		return IndexStatusModel{}, 0, fmt.Errorf("index dir %s not found", ix.Dir)
+	}
+
+	sizes := map[string]int64{}
+	var chunks []ChunkModel
+	skipped := 0
+	err := attachpkg.Walk(ix.Dir, "", func(rel string) bool {
+		full := filepath.Join(ix.Dir, rel)
+		info, err := os.Stat(full)
+		if err != nil || info.Size() > ix.MaxFileSize {
+			skipped++
+			return true
+		}
+		data, err := os.ReadFile(full)
+		if err != nil || attachpkg.IsBinary(data) {
+			skipped++
+			return true
+		}
+		sizes[rel] = info.Size()
+		chunks = append(chunks, ix.Split(rel, string(data))...)
+		return true
+	})
+	if err != nil {
+		return IndexStatusModel{}, skipped, err
+	}
+
+	if err := ix.db.ReplaceIndex(sizes, chunks); err != nil {
+		return IndexStatusModel{}, skipped, err
+	}
+	status, err := ix.db.IndexStatus()
+	return status, skipped, err
+}
+
+// Split corta el contenido en fragmentos de ChunkLines líneas que se solapan
+// ChunkOverlap líneas; las líneas se numeran desde 1
+func (ix *Index) Split(path string, content string) []ChunkModel {
+	lines := strings.Split(strings.TrimRight(content, "\n"), "\n")
+	var chunks []ChunkModel
+	step := ix.ChunkLines - ix.ChunkOverlap
+	for start := 0; start < len(lines); start += step {
+		end := min(start+ix.ChunkLines, len(lines))
+		text := strings.Join(lines[start:end], "\n")
+		if strings.TrimSpace(text) != "" {
+			chunks = append(chunks, ChunkModel{
+				Path:      path,
+				StartLine: start + 1,
+				EndLine:   end,
+				Content:   text,
+			})
+		}
+		if end == len(lines) {
+			break
+		}
+	}
+	return chunks
+}
+
+func (ix *Index) Status() (IndexStatusModel, error) {
+	return ix.db.IndexStatus()
+}
+
+// Search devuelve los TopK fragmentos más relevantes para el texto
+func (ix *Index) Search(text string) ([]ChunkModel, error) {
+	query := Query(text)
+	if query == "" {
+		return nil, nil
+	}
+	return ix.db.SearchChunks(query, ix.TopK)
+}
+
+// Query traduce el texto libre a una expresión FTS5: cada término significativo
+// entre comillas (sin operadores del usuario) unidos por OR; bm25 premia los que coinciden más
+func Query(text string) string {
+	var terms []string
+	seen := map[string]bool{}
+	for _, word := range wordPattern.FindAllString(strings.ToLower(text), -1) {
+		if stopwords[word] || seen[word] {
+			continue
+		}
+		seen[word] = true
+		terms = append(terms, `"`+word+`"`)
+		if len(terms) == maxQueryTerms {
+			break
+		}
+	}
+	return strings.Join(terms, " OR ")
+}
+
+// Context presenta los fragmentos como contexto numerado para el modelo
+func Context(chunks []ChunkModel) string {
+	var sb strings.Builder
+	sb.WriteString("Fragmentos del proyecto recuperados del índice local para esta pregunta. ")
+	sb.WriteString("Úsalos solo si son relevantes y cita la fuente como [n] (ruta:líneas).\n")
+	for idx, chunk := range chunks {
+		sb.WriteString(fmt.Sprintf("\n[%d] %s\n", idx+1, Citation(chunk)))
+		sb.WriteString(chunk.Content)
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// Citation es la referencia "ruta:inicio-fin" de un fragmento
+func Citation(chunk ChunkModel) string {
+	return fmt.Sprintf("%s:%d-%d", chunk.Path, chunk.StartLine, chunk.EndLine)
+}
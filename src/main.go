@@ -9,13 +9,19 @@ import (
 	"syscall"
 
 	agentspkg "main/src/agents"
+	attachpkg "main/src/attach"
+	budgetpkg "main/src/budget"
 	buspkg "main/src/bus"
+	cachepkg "main/src/cache"
 	commandpkg "main/src/command"
 	configpkg "main/src/config"
 	databasepkg "main/src/database"
 	eventpkg "main/src/event"
+	indexpkg "main/src/index"
 	managerpkg "main/src/manager"
 	messagepkg "main/src/message"
+	routerpkg "main/src/router"
+	settingspkg "main/src/settings"
 	tuipkg "main/src/tui"
 )
 
@@ -39,12 +45,40 @@ func main() {
 	defer bus.Close()
 
 	mgr := managerpkg.NewManager(ctx, logger)
+	mgr.SetBus(bus)
 
 	messages := messagepkg.NewMessageList(db)
+	attacher, err := attachpkg.NewAttacher(conf.Config.Attachments)
+	if err != nil {
+		logger.Error("Error loading attachments", "error", err)
+	} else {
+		messages.SetAttacher(attacher)
+	}
 
 	command := commandpkg.NewCommand(logger, conf, bus, db, mgr, messages)
+	messages.OnCreateThread(command.ApplyDefaultPersona)
+
+	router := routerpkg.NewRouter(logger, conf, mgr)
+
+	budget := budgetpkg.NewBudget(conf, db)
+	command.SetBudget(budget)
+
+	settings := settingspkg.NewSettings(db)
+	command.SetSettings(settings)
+
+	index, err := indexpkg.NewIndex(conf.Config.Retrieval, db)
+	if err != nil {
+		logger.Error("Error loading index", "error", err)
+	}
+	command.SetIndex(index)
+
+	cache, err := cachepkg.NewCache(conf.Config.Cache, db)
+	if err != nil {
+		logger.Error("Error loading cache", "error", err)
+	}
+	command.SetCache(cache)
 
-	tui := tuipkg.NewTUI(conf, bus, messages, command, logger)
+	tui := tuipkg.NewTUI(conf, bus, messages, command, router, settings, logger)
 
 	ev_sy, unsub_sy, err_sy := bus.Subscribe(eventpkg.EvtSystem, 64)
 	go bus.RuntimeCaller(tui.Program(), ev_sy, err_sy)
@@ -54,8 +88,39 @@ func main() {
 	go bus.RuntimeCaller(tui.Program(), ev_ms, err_ms)
 	defer unsub_ms()
 
-	mgr.Register(&agentspkg.EchoAgent{Logger: logger, Bus: bus, Command: command}, true)
-	mgr.Register(&agentspkg.AAgent{Logger: logger, Bus: bus, Command: command}, true)
+	ev_pt, unsub_pt, err_pt := bus.Subscribe(eventpkg.EvtPartial, 64)
+	go bus.RuntimeCaller(tui.Program(), ev_pt, err_pt)
+	defer unsub_pt()
+
+	ev_ap, unsub_ap, err_ap := bus.Subscribe(eventpkg.EvtApproval, 64)
+	go bus.RuntimeCaller(tui.Program(), ev_ap, err_ap)
+	defer unsub_ap()
+
+	ev_ag, unsub_ag, err_ag := bus.Subscribe(eventpkg.EvtAgent, 64)
+	go bus.RuntimeCaller(tui.Program(), ev_ag, err_ag)
+	defer unsub_ag()
+
+	tools := agentspkg.NewToolRegistry()
+	if err := agentspkg.RegisterBuiltinTools(tools, conf.Config.Tools, db); err != nil {
+		logger.Error("Error loading tools", "error", err)
+	}
+
+	loader := agentspkg.NewLoader(conf, configpkg.ConfigPath, mgr, agentspkg.Deps{
+		Logger:   logger,
+		Bus:      bus,
+		Command:  command,
+		Db:       db,
+		Tools:    tools,
+		Budget:   budget,
+		Settings: settings,
+		Index:    index,
+		Cache:    cache,
+	})
+	loader.SetRouter(router)
+	command.SetAgentLoader(loader)
+	if _, _, _, err := loader.Apply(conf); err != nil {
+		logger.Error("Error loading agents", "error", err)
+	}
 	defer mgr.StopAll()
 
 	if _, err := tui.Run(ctx, cancel); err != nil {
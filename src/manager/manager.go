@@ -2,11 +2,21 @@ package managerpkg
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
+	"runtime/debug"
+	"sort"
 	"sync"
 	"time"
+
+	buspkg "main/src/bus"
+	eventpkg "main/src/event"
+	modelpkg "main/src/model"
 )
 
+type AgentEventModel = modelpkg.AgentEventModel
+
 // =========================
 // Contrato Agent y Manager dinámico
 // =========================
@@ -17,13 +27,42 @@ type Agent interface {
 	Start(ctx context.Context) error
 }
 
+// Errores de las operaciones sobre agentes; se comparan con errors.Is
+var (
+	ErrUnknownAgent  = errors.New("unknown agent")
+	ErrAgentDisabled = errors.New("agent disabled")
+	ErrForcedStop    = errors.New("forced stop")
+)
+
+// UnknownAgentError indica que no hay ningún agente registrado con Name
+type UnknownAgentError struct {
+	Name string
+}
+
+func (e *UnknownAgentError) Error() string {
+	return fmt.Sprintf("unknown agent %q", e.Name)
+}
+
+func (e *UnknownAgentError) Unwrap() error {
+	return ErrUnknownAgent
+}
+
+// Drainer lo implementan los agentes que saben parar con orden: al
+// detenerlos, el Manager llama a Drain antes de cancelar su contexto para
+// que terminen o devuelvan el trabajo en curso antes de que venza ctx
+type Drainer interface {
+	Drain(ctx context.Context) error
+}
+
 // Manager gestiona el ciclo de vida de los agentes
 type Manager struct {
 	log *slog.Logger
+	bus *buspkg.OptimizedBus // nil = sin eventos de ciclo de vida
 
 	mu     sync.Mutex
 	ctx    context.Context
 	agents map[string]*runner
+	order  []string // orden de registro; define "el resto" en RestForOne
 }
 
 type runner struct {
@@ -31,11 +70,23 @@ type runner struct {
 	autoRestart bool
 	minBackoff  time.Duration
 	maxBackoff  time.Duration
-
-	mu       sync.Mutex
-	state    string // "stopped" | "running"
-	restarts int
-	lastErr  error
+	group       string
+	strategy    string
+	maxRestarts int
+	window      time.Duration
+	stableAfter time.Duration
+	stopTimeout time.Duration
+
+	mu        sync.Mutex
+	state     string // "stopped" | "running" | "stopping" | "failed"
+	restarts  int    // reinicios seguidos; marcan el backoff
+	failures  []time.Time
+	lastErr   error
+	panics    int
+	lastPanic *PanicError
+	panicked  bool // la última salida de Start fue un panic
+	forced    bool // la última parada venció stopTimeout sin que Start terminara
+	disabled  bool // no se arranca hasta volver a habilitarlo
 
 	parentCtx context.Context
 	runCtx    context.Context
@@ -44,6 +95,28 @@ type runner struct {
 	stopping  bool
 }
 
+// PanicError es un panic de Agent.Start convertido en error, con su traza
+type PanicError struct {
+	Value any
+	Stack string
+	At    time.Time
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// run ejecuta Start aislando los panics: el agente cae solo y su error
+// sigue el camino normal de reinicio
+func (r *runner) run() (err error) {
+	defer func() {
+		if value := recover(); value != nil {
+			err = &PanicError{Value: value, Stack: string(debug.Stack()), At: time.Now()}
+		}
+	}()
+	return r.agent.Start(r.runCtx)
+}
+
 // NewManager crea un nuevo Manager de agentes
 func NewManager(ctx context.Context, log *slog.Logger) *Manager {
 	return &Manager{
@@ -53,45 +126,201 @@ func NewManager(ctx context.Context, log *slog.Logger) *Manager {
 	}
 }
 
-// Register registra un agente en el Manager
+// SetBus publica en el bus los cambios de estado de los agentes (EvtAgent)
+func (m *Manager) SetBus(bus *buspkg.OptimizedBus) {
+	m.bus = bus
+}
+
+// emit publica un evento de ciclo de vida; Publish no bloquea y puede
+// llamarse con los mutex tomados
+func (m *Manager) emit(event AgentEventModel) {
+	if m.bus == nil {
+		return
+	}
+	event.Time = time.Now()
+	m.bus.Publish(eventpkg.EvtAgent, event)
+}
+
+// errText es el texto del error para los eventos; vacío si no hay error
+func errText(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
+
+// Estrategias de supervisión de un grupo: a quién más reinicia la caída de un agente
+const (
+	OneForOne  = "one_for_one"  // solo al agente que cae
+	OneForAll  = "one_for_all"  // a todo el grupo
+	RestForOne = "rest_for_one" // al agente y a los registrados después que él en el grupo
+)
+
+// Options configura el reinicio automático de un agente
+type Options struct {
+	AutoRestart bool
+	MinBackoff  time.Duration // vacío = 100ms
+	MaxBackoff  time.Duration // vacío = 5s
+	Group       string        // grupo de supervisión; vacío = el agente va solo
+	Strategy    string        // vacío = OneForOne; la del agente que cae decide qué se reinicia
+	MaxRestarts int           // reinicios permitidos dentro de Window; 0 = 5, negativo = sin límite
+	Window      time.Duration // vacío = 1m
+	StableAfter time.Duration // tiempo en marcha que vuelve a poner el backoff a cero; vacío = 30s
+	StopTimeout time.Duration // margen para Drain y, tras cancelar, para que Start termine; vacío = 5s
+	Disabled    bool          // se registra deshabilitado; StartAgent devuelve ErrAgentDisabled
+}
+
+// Register registra un agente en el Manager con reinicios ilimitados, como
+// antes de existir las Options; el tope de MaxRestarts se pide con
+// RegisterWithOptions
 func (m *Manager) Register(agent Agent, autoRestart bool) {
+	m.RegisterWithOptions(agent, Options{AutoRestart: autoRestart, MaxRestarts: -1})
+}
+
+// RegisterWithOptions registra un agente con su política de reinicio
+func (m *Manager) RegisterWithOptions(agent Agent, opts Options) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = 100 * time.Millisecond
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = 5 * time.Second
+	}
+	if opts.Strategy == "" {
+		opts.Strategy = OneForOne
+	}
+	if opts.MaxRestarts == 0 {
+		opts.MaxRestarts = 5
+	}
+	if opts.Window <= 0 {
+		opts.Window = time.Minute
+	}
+	if opts.StableAfter <= 0 {
+		opts.StableAfter = 30 * time.Second
+	}
+	if opts.StopTimeout <= 0 {
+		opts.StopTimeout = 5 * time.Second
+	}
+
 	name := agent.Name()
 	if m.agents == nil {
 		m.agents = make(map[string]*runner)
 	}
 	if _, exists := m.agents[name]; exists {
 		m.log.Warn("replacing existing agent", "name", name)
+	} else {
+		m.order = append(m.order, name)
 	}
 
 	m.agents[name] = &runner{
 		agent:       agent,
-		autoRestart: autoRestart,
-		minBackoff:  100 * time.Millisecond,
-		maxBackoff:  5 * time.Second,
+		autoRestart: opts.AutoRestart,
+		minBackoff:  opts.MinBackoff,
+		maxBackoff:  opts.MaxBackoff,
+		group:       opts.Group,
+		strategy:    opts.Strategy,
+		maxRestarts: opts.MaxRestarts,
+		window:      opts.Window,
+		stableAfter: opts.StableAfter,
+		stopTimeout: opts.StopTimeout,
+		disabled:    opts.Disabled,
 		state:       "stopped",
 	}
+	m.emit(AgentEventModel{Name: name, State: modelpkg.AgentRegistered})
 }
 
-// StartAgent inicia un agente específico
-func (m *Manager) StartAgent(name string) error {
+// Unregister detiene el agente y lo quita del Manager
+func (m *Manager) Unregister(name string) error {
+	// una parada forzada ya quedó registrada: el agente se quita igual //
+	if err := m.StopAgent(name); err != nil && !errors.Is(err, ErrForcedStop) {
+		return err
+	}
+
+	m.mu.Lock()
+	delete(m.agents, name)
+	for idx, item := range m.order {
+		if item == name {
+			m.order = append(m.order[:idx], m.order[idx+1:]...)
+			break
+		}
+	}
+	m.mu.Unlock()
+
+	m.emit(AgentEventModel{Name: name, State: modelpkg.AgentRemoved})
+	m.log.Info("agent unregistered", "name", name)
+	return nil
+}
+
+// runner busca el agente registrado con ese nombre
+func (m *Manager) runner(name string) (*runner, error) {
 	m.mu.Lock()
+	defer m.mu.Unlock()
 	r, exists := m.agents[name]
 	if !exists {
-		m.mu.Unlock()
+		return nil, &UnknownAgentError{Name: name}
+	}
+	return r, nil
+}
+
+// SetEnabled habilita o deshabilita un agente; deshabilitarlo lo detiene
+func (m *Manager) SetEnabled(name string, enabled bool) error {
+	r, err := m.runner(name)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.disabled = !enabled
+	r.mu.Unlock()
+
+	if enabled {
 		return nil
 	}
+	return m.StopAgent(name)
+}
+
+// IsRunning indica si el agente está en ejecución
+func (m *Manager) IsRunning(name string) bool {
+	m.mu.Lock()
+	r, exists := m.agents[name]
 	m.mu.Unlock()
+	if !exists {
+		return false
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.state == "running"
+}
+
+// Has indica si hay un agente registrado con ese nombre
+func (m *Manager) Has(name string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, exists := m.agents[name]
+	return exists
+}
+
+// StartAgent inicia un agente específico
+func (m *Manager) StartAgent(name string) error {
+	r, err := m.runner(name)
+	if err != nil {
+		return err
+	}
 
 	r.mu.Lock()
 	defer r.mu.Unlock()
 	if r.state == "running" {
 		return nil
 	}
-	if r.stopping {
-		return nil
+	if r.disabled {
+		return fmt.Errorf("agent %s: %w", name, ErrAgentDisabled)
+	}
+	// nunca dos instancias a la vez: la anterior tiene que haber salido //
+	if r.alive() {
+		return fmt.Errorf("agent %s: previous instance still stopping", name)
 	}
 
 	// Crea contexto para la ejecución del agente
@@ -100,45 +329,115 @@ func (m *Manager) StartAgent(name string) error {
 	r.cancel = cancel
 	r.parentCtx = m.ctx
 	r.done = make(chan struct{})
+	// un agente marcado failed vuelve a empezar con el historial limpio //
+	if r.state == "failed" {
+		r.failures = nil
+		r.restarts = 0
+	}
 	r.state = "running"
+	r.panicked = false
+	r.forced = false
+	done := r.done
 
 	m.log.Info("starting agent", "name", name)
+	m.emit(AgentEventModel{Name: name, State: modelpkg.AgentStarting, Restarts: r.restarts})
 
 	// Inicia el agente en una goroutine
 	go func() {
-		defer close(r.done)
+		defer func() {
+			r.mu.Lock()
+			r.stopping = false
+			r.mu.Unlock()
+			close(done)
+		}()
 		for {
 			select {
-			case <-r.parentCtx.Done():
+			case <-r.runCtx.Done():
 				r.mu.Lock()
 				r.state = "stopped"
+				m.emit(AgentEventModel{Name: name, State: modelpkg.AgentStopped, Restarts: r.restarts})
 				r.mu.Unlock()
 				return
 			default:
 			}
 
 			// Ejecuta el agente
-			err := r.agent.Start(r.runCtx)
+			m.emit(AgentEventModel{Name: name, State: modelpkg.AgentRunning, Restarts: r.restarts})
+			started := time.Now()
+			err := r.run()
 
 			r.mu.Lock()
+			var perr *PanicError
+			r.panicked = errors.As(err, &perr)
+			if r.panicked {
+				r.panics++
+				r.lastPanic = perr
+				m.log.Error("agent panicked", "name", name, "panic", perr.Value, "stack", perr.Stack)
+			}
 			// Si el contexto fue cancelado, termina normalmente
 			if err == context.Canceled || r.runCtx.Err() == context.Canceled {
 				r.state = "stopped"
+				m.emit(AgentEventModel{Name: name, State: modelpkg.AgentStopped, Restarts: r.restarts})
 				r.mu.Unlock()
 				return
 			}
 
 			// Si hay error y debe reiniciarse, lo intenta de nuevo con backoff
 			if err != nil && r.autoRestart && !r.stopping {
-				r.restarts++
 				r.lastErr = err
+				// Tras un periodo estable el backoff vuelve a empezar //
+				if time.Since(started) >= r.stableAfter {
+					r.restarts = 0
+				}
+
+				// Demasiados reinicios dentro de la ventana: el agente queda failed //
+				if !r.allowRestart(time.Now()) {
+					r.state = "failed"
+					m.log.Error("agent failed, too many restarts", "name", name, "error", err,
+						"max_restarts", r.maxRestarts, "window", r.window)
+					m.emit(AgentEventModel{
+						Name:     name,
+						State:    modelpkg.AgentFailed,
+						Restarts: r.restarts,
+						Err:      err.Error(),
+						Panicked: r.panicked,
+					})
+					group, affected := r.group, m.affected(name, r)
+					r.mu.Unlock()
+					// los que dependen de él en el grupo no siguen sin él //
+					if len(affected) > 0 {
+						m.log.Warn("stopping supervision group", "group", group, "failed", name, "agents", affected)
+						go m.stopGroup(affected)
+					}
+					return
+				}
+
+				r.restarts++
 				backoff := r.minBackoff * time.Duration(1<<(r.restarts-1))
 				if backoff > r.maxBackoff {
 					backoff = r.maxBackoff
 				}
 				m.log.Error("agent error, restarting", "name", name, "error", err, "backoff", backoff, "restarts", r.restarts)
+				m.emit(AgentEventModel{
+					Name:     name,
+					State:    modelpkg.AgentRestarting,
+					Restarts: r.restarts,
+					Backoff:  backoff,
+					Err:      err.Error(),
+					Panicked: r.panicked,
+				})
+				group, affected := r.group, m.affected(name, r)
 				r.mu.Unlock()
-				time.Sleep(backoff)
+				if len(affected) > 0 {
+					m.log.Warn("restarting supervision group", "group", group, "crashed", name, "agents", affected)
+					go m.restartGroup(affected)
+				}
+
+				// la espera se corta si se detiene el agente //
+				select {
+				case <-time.After(backoff):
+				case <-r.runCtx.Done():
+				}
 				continue
 			}
 
@@ -150,6 +449,13 @@ func (m *Manager) StartAgent(name string) error {
 			} else {
 				m.log.Info("agent terminated", "name", name)
 			}
+			m.emit(AgentEventModel{
+				Name:     name,
+				State:    modelpkg.AgentStopped,
+				Restarts: r.restarts,
+				Err:      errText(err),
+				Panicked: r.panicked,
+			})
 			r.mu.Unlock()
 			return
 		}
@@ -158,60 +464,163 @@ func (m *Manager) StartAgent(name string) error {
 	return nil
 }
 
-// StopAgent detiene un agente específico
-func (m *Manager) StopAgent(name string) error {
-	m.mu.Lock()
-	r, exists := m.agents[name]
-	if !exists {
-		m.mu.Unlock()
+// allowRestart anota una caída y dice si cabe en MaxRestarts dentro de la ventana
+func (r *runner) allowRestart(now time.Time) bool {
+	recent := r.failures[:0]
+	for _, at := range r.failures {
+		if now.Sub(at) < r.window {
+			recent = append(recent, at)
+		}
+	}
+	r.failures = append(recent, now)
+	return r.maxRestarts < 0 || len(r.failures) <= r.maxRestarts
+}
+
+// affected devuelve los compañeros de grupo a los que alcanza la caída de
+// name según su estrategia, en orden de registro. Se llama con r.mu tomado
+func (m *Manager) affected(name string, r *runner) []string {
+	if r.group == "" || r.strategy == OneForOne {
 		return nil
 	}
-	m.mu.Unlock()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var names []string
+	after := false
+	for _, item := range m.order {
+		if item == name {
+			after = true
+			continue
+		}
+		other, exists := m.agents[item]
+		if !exists || other.group != r.group {
+			continue
+		}
+		if r.strategy == OneForAll || (r.strategy == RestForOne && after) {
+			names = append(names, item)
+		}
+	}
+	return names
+}
+
+// restartGroup reinicia los compañeros de grupo que siguen en marcha:
+// los detiene en orden inverso y los arranca en orden de registro
+func (m *Manager) restartGroup(names []string) {
+	var running []string
+	for _, name := range names {
+		if m.IsRunning(name) {
+			running = append(running, name)
+		}
+	}
+	for idx := len(running) - 1; idx >= 0; idx-- {
+		m.StopAgent(running[idx])
+	}
+	for _, name := range running {
+		m.StartAgent(name)
+	}
+}
+
+// stopGroup detiene los compañeros de grupo en orden inverso
+func (m *Manager) stopGroup(names []string) {
+	for idx := len(names) - 1; idx >= 0; idx-- {
+		m.StopAgent(names[idx])
+	}
+}
+
+// alive indica si la goroutine de la última ejecución sigue viva. Se llama con r.mu tomado
+func (r *runner) alive() bool {
+	if r.done == nil {
+		return false
+	}
+	select {
+	case <-r.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// StopAgent detiene un agente: le deja drenar hasta stopTimeout si implementa
+// Drainer, cancela su contexto y espera otro stopTimeout a que Start termine.
+// Si no termina, la parada queda como forzada (ErrForcedStop) y el agente en
+// "stopped"; no se vuelve a arrancar hasta que su goroutine salga
+func (m *Manager) StopAgent(name string) error {
+	r, err := m.runner(name)
+	if err != nil {
+		return err
+	}
 
 	r.mu.Lock()
+	if r.state == "stopping" {
+		r.mu.Unlock()
+		return fmt.Errorf("agent %s is still stopping", name)
+	}
 	if r.state != "running" {
 		r.mu.Unlock()
 		return nil
 	}
 	r.stopping = true
+	r.state = "stopping"
 	cancel := r.cancel
 	done := r.done
+	timeout := r.stopTimeout
 	r.mu.Unlock()
 
+	// Drain: el agente termina o devuelve lo que tiene en curso //
+	if drainer, ok := r.agent.(Drainer); ok {
+		ctx, stop := context.WithTimeout(context.Background(), timeout)
+		m.log.Info("draining agent", "name", name, "timeout", timeout)
+		if err := drainer.Drain(ctx); err != nil {
+			m.log.Warn("agent drain incomplete", "name", name, "error", err)
+		}
+		stop()
+	}
+
 	// Cancelar el contexto
-	// m.log.Info("stopping agent", "name", name)
 	if cancel != nil {
 		cancel()
 	}
 
-	// Esperar a que la goroutine termine
+	// Esperar a que la goroutine termine; tras cancelar tiene su propio margen //
 	select {
 	case <-done:
-		// OK, terminó normalmente
-	case <-time.After(5 * time.Second):
-		// m.log.Warn("agent stop timeout", "name", name)
+		return nil
+	case <-time.After(timeout):
 	}
 
+	// Start no respetó la cancelación: se informa y no se arranca otra instancia //
 	r.mu.Lock()
-	r.stopping = false
+	r.forced = true
 	r.state = "stopped"
+	r.lastErr = fmt.Errorf("%w: still running %s after cancel", ErrForcedStop, timeout)
+	m.emit(AgentEventModel{Name: name, State: modelpkg.AgentStopped, Restarts: r.restarts, Err: r.lastErr.Error()})
 	r.mu.Unlock()
-
-	return nil
+	m.log.Error("agent stop timeout", "name", name, "timeout", timeout)
+	return fmt.Errorf("agent %s did not stop within %s of cancel: %w", name, timeout, ErrForcedStop)
 }
 
-// RestartAgent reinicia un agente específico
+// RestartAgent reinicia un agente específico; si la instancia anterior no
+// llega a salir no se arranca la nueva
 func (m *Manager) RestartAgent(name string) error {
-	m.StopAgent(name)
+	if err := m.StopAgent(name); err != nil {
+		return err
+	}
 	return m.StartAgent(name)
 }
 
 // AgentStatus representa el estado actual de un agente
 type AgentStatus struct {
-	Name     string
-	State    string
-	Restarts int
-	LastErr  error
+	Name      string
+	State     string
+	Group     string
+	Strategy  string
+	Restarts  int
+	LastErr   error
+	Panicked  bool        // la última caída fue un panic
+	Forced    bool        // la última parada venció el StopTimeout
+	Disabled  bool        // no se puede arrancar hasta habilitarlo
+	Panics    int         // panics desde que se registró
+	LastPanic *PanicError // último panic con su traza; nil si nunca
 }
 
 // ListAgents devuelve una lista con el estado de todos los agentes
@@ -223,6 +632,8 @@ func (m *Manager) ListAgents() []AgentStatus {
 	}
 	m.mu.Unlock()
 
+	sort.Strings(names)
+
 	var result []AgentStatus
 	for _, name := range names {
 		m.mu.Lock()
@@ -235,10 +646,17 @@ func (m *Manager) ListAgents() []AgentStatus {
 
 		r.mu.Lock()
 		status := AgentStatus{
-			Name:     name,
-			State:    r.state,
-			Restarts: r.restarts,
-			LastErr:  r.lastErr,
+			Name:      name,
+			State:     r.state,
+			Group:     r.group,
+			Strategy:  r.strategy,
+			Restarts:  r.restarts,
+			LastErr:   r.lastErr,
+			Panicked:  r.panicked,
+			Forced:    r.forced,
+			Disabled:  r.disabled,
+			Panics:    r.panics,
+			LastPanic: r.lastPanic,
 		}
 		r.mu.Unlock()
 		result = append(result, status)
@@ -246,13 +664,20 @@ func (m *Manager) ListAgents() []AgentStatus {
 	return result
 }
 
-// StartAll inicia todos los agentes registrados
+// AgentStatus devuelve el estado de un agente
+func (m *Manager) AgentStatus(name string) (AgentStatus, bool) {
+	for _, status := range m.ListAgents() {
+		if status.Name == name {
+			return status, true
+		}
+	}
+	return AgentStatus{}, false
+}
+
+// StartAll inicia todos los agentes registrados, en orden de registro
 func (m *Manager) StartAll() {
 	m.mu.Lock()
-	var names []string
-	for name := range m.agents {
-		names = append(names, name)
-	}
+	names := append([]string{}, m.order...)
 	m.mu.Unlock()
 
 	for _, name := range names {
@@ -260,16 +685,13 @@ func (m *Manager) StartAll() {
 	}
 }
 
-// StopAll detiene todos los agentes registrados
+// StopAll detiene todos los agentes registrados, en orden inverso
 func (m *Manager) StopAll() {
 	m.mu.Lock()
-	var names []string
-	for name := range m.agents {
-		names = append(names, name)
-	}
+	names := append([]string{}, m.order...)
 	m.mu.Unlock()
 
-	for _, name := range names {
-		m.StopAgent(name)
+	for idx := len(names) - 1; idx >= 0; idx-- {
+		m.StopAgent(names[idx])
 	}
 }
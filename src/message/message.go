@@ -3,6 +3,7 @@ package messagepkg
 import (
 	"time"
 
+	attachpkg "main/src/attach"
 	databasepkg "main/src/database"
 	modelpkg "main/src/model"
 	toolspkg "main/src/tools"
@@ -13,12 +14,24 @@ type MessageModel = modelpkg.MessageModel
 type MessageType = modelpkg.MessageType
 type MessageSource = modelpkg.MessageSource
 type ThreadModel = modelpkg.ThreadModel
+type AttachmentModel = modelpkg.AttachmentModel
+type Attacher = attachpkg.Attacher
 
 type MessageList struct {
 	db       *Database
 	Messages []MessageModel
 	Thread   *ThreadModel
 	Threads  []ThreadModel
+	Unread   map[string]int    // mensajes recibidos en hilos que no están en pantalla
+	Tokens   int               // tokens consumidos en el hilo actual
+	Pending  []AttachmentModel // adjuntos de `/attach` para el próximo mensaje
+	attacher *Attacher
+	partials map[string]bool     // ids de mensajes en streaming aún no guardados
+	children map[string][]string // hijos de cada mensaje del hilo actual (ramas)
+	branches map[string]bool     // mensajes creados por Branch; conservan su padre
+	replies  map[string]string   // último mensaje de cada respuesta en curso (ReplyTo + agente)
+	all      bool                // la vista incluye mensajes de sistema y comandos
+	onCreate func(thread *ThreadModel)
 }
 
 func NewMessageList(db *Database) *MessageList {
@@ -29,6 +42,11 @@ func NewMessageList(db *Database) *MessageList {
 		Messages: []MessageModel{},
 		Thread:   nil,
 		Threads:  threads,
+		Unread:   map[string]int{},
+		partials: map[string]bool{},
+		children: map[string][]string{},
+		branches: map[string]bool{},
+		replies:  map[string]string{},
 	}
 }
 
@@ -58,16 +76,329 @@ func (ml *MessageList) ControlThread(name string) {
 		Name:      toolspkg.CutString(name, 0, 10),
 		CreatedAt: time.Now(),
 	})
+	if ml.Thread != nil {
+		if ml.onCreate != nil {
+			ml.onCreate(ml.Thread)
+		}
+		ml.Threads = append(ml.Threads, *ml.Thread)
+	}
+	ml.Tokens = 0
+	ml.children = map[string][]string{}
 }
 
-func (ml *MessageList) AddMessage(message MessageModel) {
-	message.CreatedAt = time.Now()
-	if message.Type != modelpkg.TyCommand && message.Type != modelpkg.TySystem {
-		ml.ControlThread(message.Text)
+// OnCreateThread registra una función que completa los threads creados
+// automáticamente (p. ej. con la persona por defecto)
+func (ml *MessageList) OnCreateThread(fn func(thread *ThreadModel)) {
+	ml.onCreate = fn
+}
+
+// SetAttacher habilita los adjuntos (`/attach` y @ruta)
+func (ml *MessageList) SetAttacher(attacher *Attacher) {
+	ml.attacher = attacher
+}
+
+// Stage añade a los adjuntos pendientes los archivos de pattern y devuelve
+// los avisos de los descartados
+func (ml *MessageList) Stage(pattern string) ([]AttachmentModel, []string) {
+	if ml.attacher == nil {
+		return nil, []string{"los adjuntos no están habilitados"}
+	}
+	items, warnings := ml.attacher.Collect(pattern, ml.Pending)
+	ml.Pending = append(ml.Pending, items...)
+	return items, warnings
+}
+
+// Attach incorpora al mensaje los adjuntos pendientes y los referenciados con
+// @ruta en el texto; devuelve los avisos de los que no se pudieron adjuntar
+func (ml *MessageList) Attach(message MessageModel) (MessageModel, []string) {
+	message.Attachments = append(message.Attachments, ml.Pending...)
+	ml.Pending = nil
+	if ml.attacher == nil {
+		return message, nil
+	}
+
+	var warnings []string
+	for _, path := range ml.attacher.Inline(message.Text) {
+		items, skipped := ml.attacher.Collect(path, message.Attachments)
+		message.Attachments = append(message.Attachments, items...)
+		warnings = append(warnings, skipped...)
+	}
+	return message, warnings
+}
+
+// SelectThread muestra el hilo dado y marca sus mensajes como leídos
+func (ml *MessageList) SelectThread(thread ThreadModel, all bool) error {
+	messages, err := ml.db.ListMessageByThreadId(thread.Id, all)
+	if err != nil {
+		return err
+	}
+	ml.Thread = &thread
+	ml.Messages = messages
+	ml.all = all
+	ml.children, _ = ml.db.Children(thread.Id)
+	delete(ml.Unread, thread.Id)
+	usage, _ := ml.db.ThreadUsage(thread.Id)
+	ml.Tokens = usage.Total()
+	return nil
+}
+
+// TotalUnread suma los mensajes sin leer de todos los hilos
+func (ml *MessageList) TotalUnread() int {
+	total := 0
+	for _, count := range ml.Unread {
+		total += count
+	}
+	return total
+}
+
+// isCurrent indica si el hilo es el que está en pantalla
+func (ml *MessageList) isCurrent(threadId string) bool {
+	return ml.Thread != nil && ml.Thread.Id == threadId
+}
+
+// Stamp asigna id e hilo a un mensaje antes de publicarlo en el bus,
+// así los agentes saben a qué conversación pertenece
+func (ml *MessageList) Stamp(message MessageModel) MessageModel {
+	if message.Id == "" {
+		message.Id = toolspkg.GenerateUUID()
 	}
+	ml.ControlThread(message.Text)
 	if ml.Thread != nil {
 		message.ThreadId = ml.Thread.Id
+		message.ParentId = ml.Thread.HeadId
+	}
+	return message
+}
+
+func (ml *MessageList) AddMessage(message MessageModel) {
+	message.CreatedAt = time.Now()
+	// el id se fija aquí: la base de datos, la cabeza y las ramas usan el mismo //
+	if message.Id == "" {
+		message.Id = toolspkg.GenerateUUID()
+	}
+	if message.ThreadId == "" {
+		if message.Type != modelpkg.TyCommand && message.Type != modelpkg.TySystem {
+			ml.ControlThread(message.Text)
+		}
+		if ml.Thread != nil {
+			message.ThreadId = ml.Thread.Id
+		}
+	}
+	visible := true
+	if message.ThreadId != "" {
+		// Los mensajes de la conversación cuelgan de la cabeza del hilo y pasan a
+		// serlo; una respuesta cuelga del mensaje que contesta, aunque entretanto
+		// se haya cambiado de rama, enviado otro mensaje o respondido otro agente //
+		advance := false
+		if message.InTree() {
+			head := ml.head(message.ThreadId)
+			anchor := ""
+			switch {
+			case ml.branches[message.Id]:
+			case message.Type == modelpkg.TySummary:
+				// el resumen abre la respuesta al mensaje de la cabeza //
+				message.ParentId = head
+				anchor = head
+			case message.ReplyTo != "":
+				// herramientas y respuesta final se encadenan tras el mensaje contestado //
+				anchor = message.ReplyTo
+				message.ParentId = message.ReplyTo
+				if last, ok := ml.replies[anchor+"@"+message.WrittenBy]; ok {
+					message.ParentId = last
+				}
+			default:
+				message.ParentId = head
+			}
+			if anchor != "" {
+				if message.Type == modelpkg.TyText {
+					delete(ml.replies, anchor+"@"+message.WrittenBy)
+				} else {
+					ml.replies[anchor+"@"+message.WrittenBy] = message.Id
+				}
+			}
+			delete(ml.branches, message.Id)
+			advance = message.ParentId == head
+			visible = advance || ml.onActivePath(message.ThreadId, message.ParentId)
+		}
 		ml.db.CreateMessage(message)
+		if message.InTree() {
+			if advance {
+				ml.db.SetThreadHead(message.ThreadId, message.Id)
+			}
+			if ml.isCurrent(message.ThreadId) {
+				if advance {
+					ml.Thread.HeadId = message.Id
+				}
+				ml.children[message.ParentId] = append(ml.children[message.ParentId], message.Id)
+			}
+		}
+	}
+
+	// La respuesta pertenece a otro hilo: se guarda allí y queda como no leída //
+	if message.ThreadId != "" && !ml.isCurrent(message.ThreadId) {
+		delete(ml.partials, message.Id)
+		if idx := ml.indexOf(message.Id); idx >= 0 {
+			ml.Messages = append(ml.Messages[:idx], ml.Messages[idx+1:]...)
+		}
+		ml.Unread[message.ThreadId]++
+		return
+	}
+
+	if message.Usage != nil {
+		ml.Tokens += message.Usage.Total()
+	}
+
+	// Responde a un mensaje que ya no está en la rama activa: queda guardado como rama aparte //
+	if !visible {
+		delete(ml.partials, message.Id)
+		if idx := ml.indexOf(message.Id); idx >= 0 {
+			ml.Messages = append(ml.Messages[:idx], ml.Messages[idx+1:]...)
+		}
+		return
+	}
+
+	// Reemplaza el parcial (streaming) si ya está en la vista //
+	if ml.partials[message.Id] {
+		delete(ml.partials, message.Id)
+		if idx := ml.indexOf(message.Id); idx >= 0 {
+			ml.Messages[idx] = message
+			return
+		}
 	}
 	ml.Messages = append(ml.Messages, message)
 }
+
+// UpdatePartial muestra un mensaje aún incompleto sin persistirlo
+func (ml *MessageList) UpdatePartial(message MessageModel) {
+	if message.Id == "" {
+		return
+	}
+	if message.ThreadId != "" && !ml.isCurrent(message.ThreadId) {
+		return
+	}
+	if message.ReplyTo != "" && !ml.onActivePath(message.ThreadId, message.ReplyTo) {
+		return
+	}
+	idx := ml.indexOf(message.Id)
+	if idx >= 0 {
+		// un delta tardío no debe pisar el mensaje final //
+		if ml.partials[message.Id] {
+			ml.Messages[idx].Text = message.Text
+		}
+		return
+	}
+	message.CreatedAt = time.Now()
+	ml.partials[message.Id] = true
+	ml.Messages = append(ml.Messages, message)
+}
+
+// head devuelve la cabeza de la rama activa de un hilo
+func (ml *MessageList) head(threadId string) string {
+	if ml.isCurrent(threadId) {
+		return ml.Thread.HeadId
+	}
+	head, _ := ml.db.ThreadHead(threadId)
+	return head
+}
+
+// onActivePath indica si el mensaje está en la rama activa del hilo
+func (ml *MessageList) onActivePath(threadId string, id string) bool {
+	if threadId == "" || ml.isCurrent(threadId) {
+		return ml.indexOf(id) >= 0
+	}
+	messages, _ := ml.db.ListBranch(threadId, ml.head(threadId), false)
+	for _, message := range messages {
+		if message.Id == id {
+			return true
+		}
+	}
+	return false
+}
+
+// reload vuelve a leer la rama activa del hilo actual
+func (ml *MessageList) reload() {
+	if ml.Thread == nil {
+		return
+	}
+	if messages, err := ml.db.ListMessageByThreadId(ml.Thread.Id, ml.all); err == nil {
+		ml.Messages = messages
+	}
+}
+
+// Branch crea una rama nueva que sale del padre de `from` y devuelve el mensaje
+// humano (hermano de `from`) que hay que publicar. La historia no se borra
+func (ml *MessageList) Branch(from MessageModel, text string) MessageModel {
+	message := MessageModel{
+		Id:       toolspkg.GenerateUUID(),
+		Type:     modelpkg.TyText,
+		Source:   modelpkg.ScHuman,
+		Text:     text,
+		ThreadId: from.ThreadId,
+		ParentId: from.ParentId,
+		To:       from.To,
+		// los adjuntos se conservan con su contenido original //
+		Attachments: from.Attachments,
+	}
+	ml.branches[message.Id] = true
+	if ml.isCurrent(from.ThreadId) {
+		ml.setHead(from.ParentId)
+	}
+	return message
+}
+
+// Siblings devuelve la posición (desde 1) del mensaje entre sus hermanos y cuántos son
+func (ml *MessageList) Siblings(message MessageModel) (int, int) {
+	siblings := ml.children[message.ParentId]
+	for idx, id := range siblings {
+		if id == message.Id {
+			return idx + 1, len(siblings)
+		}
+	}
+	return 0, len(siblings)
+}
+
+// Flip cambia a la rama hermana siguiente (delta 1) o anterior (-1) en el
+// último punto de la rama activa donde hay alternativas
+func (ml *MessageList) Flip(delta int) bool {
+	for idx := len(ml.Messages) - 1; idx >= 0; idx-- {
+		message := ml.Messages[idx]
+		if !message.InTree() {
+			continue
+		}
+		if siblings := ml.children[message.ParentId]; len(siblings) > 1 {
+			pos, total := ml.Siblings(message)
+			target := siblings[((pos-1+delta)%total+total)%total]
+			ml.setHead(ml.latestLeaf(target))
+			return true
+		}
+	}
+	return false
+}
+
+// latestLeaf baja desde el mensaje por los hijos más recientes hasta una hoja
+func (ml *MessageList) latestLeaf(id string) string {
+	for {
+		children := ml.children[id]
+		if len(children) == 0 {
+			return id
+		}
+		id = children[len(children)-1]
+	}
+}
+
+// setHead activa otra rama; el estado remoto de los agentes pertenecía a la anterior
+func (ml *MessageList) setHead(headId string) {
+	ml.Thread.HeadId = headId
+	ml.db.SetThreadHead(ml.Thread.Id, headId)
+	ml.db.ClearConversations(ml.Thread.Id)
+	ml.reload()
+}
+
+func (ml *MessageList) indexOf(id string) int {
+	for idx := len(ml.Messages) - 1; idx >= 0; idx-- {
+		if ml.Messages[idx].Id == id {
+			return idx
+		}
+	}
+	return -1
+}
@@ -14,6 +14,8 @@ const (
 	TySystem MessageType = iota
 	TyText
 	TyCommand
+	TyTool    // llamada a herramienta hecha por un agente y su resultado
+	TySummary // resumen de los mensajes anteriores (ReplyTo = último mensaje resumido)
 )
 
 func (mt MessageType) String() string {
@@ -21,6 +23,8 @@ func (mt MessageType) String() string {
 		"System",
 		"Text",
 		"Command",
+		"Tool",
+		"Summary",
 	}[mt]
 }
 
@@ -41,13 +45,213 @@ func (mf MessageSource) String() string {
 }
 
 type MessageModel struct {
+	Id          string            `json:"id"`
+	Type        MessageType       `json:"type"`
+	Source      MessageSource     `json:"source"`
+	WrittenBy   string            `json:"written_by"`
+	Text        string            `json:"text"`
+	ThreadId    string            `json:"thread_id"`
+	ReplyTo     string            `json:"reply_to,omitempty"`    // id del mensaje al que responde (correlación)
+	To          []string          `json:"to,omitempty"`          // agentes destinatarios; vacío = todos
+	ParentId    string            `json:"parent_id,omitempty"`   // mensaje anterior en su rama
+	Usage       *UsageModel       `json:"usage,omitempty"`       // tokens consumidos por la respuesta
+	Attachments []AttachmentModel `json:"attachments,omitempty"` // archivos adjuntos (`/attach`, @ruta)
+	Cached      bool              `json:"cached,omitempty"`      // respuesta servida desde la caché
+	CreatedAt   time.Time         `json:"created_at"`
+}
+
+// InTree indica si el mensaje forma parte de la conversación ramificada;
+// los de sistema y los comandos quedan fuera de las ramas
+func (m MessageModel) InTree() bool {
+	return m.Type == TyText || m.Type == TyTool || m.Type == TySummary
+}
+
+// IsAddressedTo indica si el agente debe atender el mensaje
+func (m MessageModel) IsAddressedTo(agent string) bool {
+	if len(m.To) == 0 {
+		return true
+	}
+	for _, name := range m.To {
+		if name == agent {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ * ATTACHMENT MODEL
+ */
+
+// AttachmentModel es un archivo de texto del workspace adjunto a un mensaje;
+// se guarda su contenido para que el historial no dependa del disco
+type AttachmentModel struct {
+	Id        string `json:"id"`
+	MessageId string `json:"message_id"`
+	Path      string `json:"path"` // relativa al workspace
+	Size      int64  `json:"size"`
+	Content   string `json:"content"`
+}
+
+/**
+ * INDEX MODEL
+ */
+
+// ChunkModel es un fragmento de archivo del índice local (RAG); Score es el
+// rango bm25 de la búsqueda (más negativo = más relevante)
+type ChunkModel struct {
+	Path      string
+	StartLine int
+	EndLine   int
+	Content   string
+	Score     float64
+}
+
+// IndexStatusModel resume el índice local
+type IndexStatusModel struct {
+	Files     int
+	Chunks    int
+	IndexedAt time.Time // cero = nunca indexado
+}
+
+/**
+ * CACHE MODEL
+ */
+
+// CacheStatsModel resume la caché de respuestas
+type CacheStatsModel struct {
+	Entries int
+	Expired int   // pendientes de purgar
+	Hits    int   // respuestas servidas desde la caché
+	Bytes   int64 // tamaño de las respuestas guardadas
+}
+
+/**
+ * USAGE MODEL
+ */
+
+// UsageModel son los tokens que consumió una respuesta del modelo
+type UsageModel struct {
+	Model           string `json:"model,omitempty"`
+	InputTokens     int    `json:"input_tokens"`
+	CachedTokens    int    `json:"cached_tokens"`
+	OutputTokens    int    `json:"output_tokens"`
+	ReasoningTokens int    `json:"reasoning_tokens"`
+}
+
+func (u UsageModel) Total() int {
+	return u.InputTokens + u.OutputTokens
+}
+
+// Add acumula el consumo de otra petición (p. ej. cada vuelta de herramientas)
+func (u *UsageModel) Add(other UsageModel) {
+	if other.Model != "" {
+		u.Model = other.Model
+	}
+	u.InputTokens += other.InputTokens
+	u.CachedTokens += other.CachedTokens
+	u.OutputTokens += other.OutputTokens
+	u.ReasoningTokens += other.ReasoningTokens
+}
+
+// UsageTotal agrega el consumo bajo una clave (hilo, agente, modelo o día)
+type UsageTotal struct {
+	Key      string
+	Messages int
+	UsageModel
+}
+
+/**
+ * SETTINGS MODEL
+ */
+
+// SettingsModel son los parámetros del modelo ajustados en tiempo de ejecución;
+// los vacíos (nil o "") dejan el valor del agente o del proveedor
+type SettingsModel struct {
+	Model           string
+	Temperature     *float64
+	TopP            *float64
+	MaxOutputTokens *int
+	ReasoningEffort string
+}
+
+/**
+ * BUDGET MODEL
+ */
+
+// BudgetModel es el límite de gasto de un ámbito ("thread" o "day");
+// 0 = sin límite
+type BudgetModel struct {
+	Scope  string
+	Tokens int
+	Cost   float64
+}
+
+/**
+ * AGENT RECORD MODEL
+ */
+
+// AgentRecordModel es el estado persistido de un agente: los creados con
+// `/agent add` guardan su declaración (JSON) y todos su habilitación
+type AgentRecordModel struct {
+	Name    string
+	Config  string // declaración JSON; vacío = declarado en config.yaml
+	Enabled bool
+}
+
+// AgentKindModel describe un tipo de agente que se puede crear con `/agent add`
+type AgentKindModel struct {
+	Name        string
+	Description string
+	Params      []string // claves propias del tipo; las de reinicio valen para todos
+}
+
+/**
+ * APPROVAL MODEL
+ */
+
+// ApprovalModel es una petición de permiso para ejecutar una herramienta
+// peligrosa; la respuesta viaja por el bus con el mismo Id y Answered = true
+type ApprovalModel struct {
 	Id        string
-	Type      MessageType
-	Source    MessageSource
-	WrittenBy string
-	Text      string
+	Agent     string
+	Tool      string
+	Arguments string
 	ThreadId  string
-	CreatedAt time.Time
+	Answered  bool
+	Approved  bool
+}
+
+/**
+ * AGENT EVENT MODEL
+ */
+
+// Estados que publica el Manager en EvtAgent
+const (
+	AgentRegistered = "registered"
+	AgentStarting   = "starting"
+	AgentRunning    = "running"
+	AgentRestarting = "restarting" // cayó con error; se reinicia tras Backoff
+	AgentStopping   = "stopping"   // la parada venció su plazo; Start aún no ha salido
+	AgentStopped    = "stopped"
+	AgentFailed     = "failed" // superó sus reinicios permitidos; no se reinicia solo
+	AgentRemoved    = "removed"
+)
+
+// AgentEventModel es un cambio en el ciclo de vida de un agente
+type AgentEventModel struct {
+	Name     string
+	State    string
+	Restarts int
+	Backoff  time.Duration // espera antes del reinicio (AgentRestarting)
+	Err      string        // motivo de la caída; vacío si no hubo error
+	Panicked bool          // la caída fue un panic
+	Time     time.Time
+}
+
+// Crashed indica si el evento informa de una caída
+func (e AgentEventModel) Crashed() bool {
+	return e.Err != ""
 }
 
 /**
@@ -55,7 +259,11 @@ type MessageModel struct {
  */
 
 type ThreadModel struct {
-	Id        string
-	Name      string
-	CreatedAt time.Time
+	Id           string
+	Name         string
+	DefaultAgent string // agente que atiende el hilo si no hay @mención
+	HeadId       string // último mensaje de la rama activa
+	Instructions string // instrucciones permanentes del hilo (`/system`)
+	Persona      string // persona aplicada con `/persona use`
+	CreatedAt    time.Time
 }
@@ -0,0 +1,106 @@
+package routerpkg
+
+import (
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+
+	configpkg "main/src/config"
+	managerpkg "main/src/manager"
+	modelpkg "main/src/model"
+)
+
+type MessageModel = modelpkg.MessageModel
+type ThreadModel = modelpkg.ThreadModel
+
+type rule struct {
+	match  *regexp.Regexp
+	agents []string
+}
+
+// Router decide qué agentes atienden cada mensaje humano:
+// 1. @menciones al inicio del texto, 2. agente por defecto del hilo,
+// 3. reglas de config y 4. la lista `default` de config
+type Router struct {
+	logger *slog.Logger
+	mgr    *managerpkg.Manager
+
+	mu       sync.RWMutex
+	defaults []string
+	rules    []rule
+}
+
+func NewRouter(logger *slog.Logger, config *configpkg.Config, mgr *managerpkg.Manager) *Router {
+	r := &Router{
+		logger: logger,
+		mgr:    mgr,
+	}
+	r.Reload(config)
+	return r
+}
+
+// Reload sustituye la lista por defecto y las reglas por las de config
+func (r *Router) Reload(config *configpkg.Config) {
+	var rules []rule
+	for _, item := range config.Config.Routing.Rules {
+		re, err := regexp.Compile(item.Match)
+		if err != nil {
+			r.logger.Error("invalid routing rule", "match", item.Match, "error", err)
+			continue
+		}
+		rules = append(rules, rule{match: re, agents: item.Agents})
+	}
+
+	r.mu.Lock()
+	r.defaults = config.Config.Routing.Default
+	r.rules = rules
+	r.mu.Unlock()
+}
+
+// Route completa los destinatarios (`To`) del mensaje y quita las @menciones del texto
+func (r *Router) Route(msg MessageModel, thread *ThreadModel) MessageModel {
+	mentions, text := r.Mentions(msg.Text)
+	if len(mentions) > 0 {
+		msg.To = mentions
+		if text != "" {
+			msg.Text = text
+		}
+		return msg
+	}
+
+	if thread != nil && thread.DefaultAgent != "" {
+		msg.To = []string{thread.DefaultAgent}
+		return msg
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, item := range r.rules {
+		if item.match.MatchString(msg.Text) {
+			msg.To = item.agents
+			return msg
+		}
+	}
+
+	msg.To = r.defaults
+	return msg
+}
+
+// Mentions separa las @menciones iniciales a agentes registrados del resto del texto
+func (r *Router) Mentions(text string) ([]string, string) {
+	var mentions []string
+	rest := strings.TrimSpace(text)
+
+	for strings.HasPrefix(rest, "@") {
+		word, tail, _ := strings.Cut(rest, " ")
+		name := strings.TrimPrefix(word, "@")
+		if !r.mgr.Has(name) {
+			break
+		}
+		mentions = append(mentions, name)
+		rest = strings.TrimSpace(tail)
+	}
+
+	return mentions, rest
+}
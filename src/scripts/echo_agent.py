@@ -0,0 +1,21 @@
+#!/usr/bin/env python3
+# Agente externo de ejemplo para el protocolo JSON-lines por stdio.
+# Config: { name: "py", kind: "process", command: "python3", args: ["src/scripts/echo_agent.py"] }
+import json
+import sys
+
+for line in sys.stdin:
+    event = json.loads(line)
+    if event.get("event") != "message":
+        continue
+    message = event["message"]
+    print("received", message["id"], file=sys.stderr, flush=True)
+    reply = {
+        "event": "message",
+        "message": {
+            "text": "Python echo: " + message["text"],
+            "thread_id": message["thread_id"],
+            "reply_to": message["id"],
+        },
+    }
+    print(json.dumps(reply), flush=True)
@@ -0,0 +1,135 @@
+package settingspkg
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+
+	databasepkg "main/src/database"
+	modelpkg "main/src/model"
+)
+
+type Database = databasepkg.Database
+type SettingsModel = modelpkg.SettingsModel
+
+// Global es el ámbito que aplica a todos los hilos
+const Global = "global"
+
+// Keys son los ajustes admitidos por `/model` y `/set`
+var Keys = []string{"model", "temperature", "top_p", "max_output_tokens", "reasoning_effort"}
+
+var efforts = []string{"minimal", "low", "medium", "high"}
+
+// Settings guarda en SQLite los ajustes globales y por hilo; el hilo manda
+// sobre el global. Se cachean porque la cabecera los lee en cada render
+type Settings struct {
+	db    *Database
+	mu    sync.Mutex
+	cache map[string]map[string]string
+}
+
+func NewSettings(db *Database) *Settings {
+	return &Settings{db: db, cache: map[string]map[string]string{}}
+}
+
+// Set valida y guarda un ajuste en el ámbito; value "-" lo elimina
+func (s *Settings) Set(scope string, key string, value string) error {
+	if !slices.Contains(Keys, key) {
+		return fmt.Errorf("setting %q not supported", key)
+	}
+	if value == "-" {
+		value = ""
+	}
+	if value != "" {
+		if err := validate(key, value); err != nil {
+			return err
+		}
+	}
+
+	if err := s.db.SetSetting(scope, key, value); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.cache, scope)
+	return nil
+}
+
+// Raw devuelve los ajustes guardados en un ámbito, sin combinar
+func (s *Settings) Raw(scope string) map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	values, ok := s.cache[scope]
+	if !ok {
+		values, _ = s.db.ListSettings(scope)
+		if values == nil {
+			values = map[string]string{}
+		}
+		s.cache[scope] = values
+	}
+	return values
+}
+
+// Resolve combina los ajustes globales con los del hilo
+func (s *Settings) Resolve(threadId string) SettingsModel {
+	var settings SettingsModel
+	scopes := []string{Global}
+	if threadId != "" {
+		scopes = append(scopes, threadId)
+	}
+	for _, scope := range scopes {
+		for key, value := range s.Raw(scope) {
+			apply(&settings, key, value)
+		}
+	}
+	return settings
+}
+
+func validate(key string, value string) error {
+	switch key {
+	case "temperature":
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil || number < 0 || number > 2 {
+			return fmt.Errorf("temperature must be between 0 and 2")
+		}
+	case "top_p":
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil || number <= 0 || number > 1 {
+			return fmt.Errorf("top_p must be greater than 0 and at most 1")
+		}
+	case "max_output_tokens":
+		number, err := strconv.Atoi(value)
+		if err != nil || number <= 0 {
+			return fmt.Errorf("max_output_tokens must be a positive integer")
+		}
+	case "reasoning_effort":
+		if !slices.Contains(efforts, value) {
+			return fmt.Errorf("reasoning_effort must be one of %v", efforts)
+		}
+	}
+	return nil
+}
+
+func apply(settings *SettingsModel, key string, value string) {
+	switch key {
+	case "model":
+		settings.Model = value
+	case "temperature":
+		if number, err := strconv.ParseFloat(value, 64); err == nil {
+			settings.Temperature = &number
+		}
+	case "top_p":
+		if number, err := strconv.ParseFloat(value, 64); err == nil {
+			settings.TopP = &number
+		}
+	case "max_output_tokens":
+		if number, err := strconv.Atoi(value); err == nil {
+			settings.MaxOutputTokens = &number
+		}
+	case "reasoning_effort":
+		settings.ReasoningEffort = value
+	}
+}
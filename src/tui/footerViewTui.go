@@ -1,9 +1,24 @@
 package tuipkg
 
-import toolspkg "main/src/tools"
+import (
+	"fmt"
+
+	toolspkg "main/src/tools"
+)
 
 func FooterViewTui(t *TUI) string {
-	text_footer_static := "ESC/Ctrl+C: Salir • PgUp/PgDn: Desplazar • ↑/↓: Historial"
+	if len(t.approvals) > 0 {
+		approval := t.approvals[0]
+		question := fmt.Sprintf("¿Permitir a %s ejecutar %s %s? [y/n + Enter]", approval.Agent, approval.Tool, approval.Arguments)
+		if len(t.approvals) > 1 {
+			question += fmt.Sprintf(" (+%d)", len(t.approvals)-1)
+		}
+		return t.styles.alert.
+			Margin(0, 3, 0, 3).
+			Render(toolspkg.CutString(question, 0, t.viewport.Width))
+	}
+
+	text_footer_static := "ESC/Ctrl+C: Salir • Ctrl+X: Cancelar • PgUp/PgDn: Desplazar • ↑/↓: Historial • Alt+,/.: Ramas"
 	if t.showAlert {
 		text_footer_static = toolspkg.SpaceBetween(
 			t.viewport.Width,
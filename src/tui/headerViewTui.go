@@ -2,6 +2,8 @@ package tuipkg
 
 import (
 	"fmt"
+	"strings"
+
 	modelpkg "main/src/model"
 
 	"github.com/charmbracelet/lipgloss"
@@ -16,9 +18,18 @@ func HeaderViewTui(t *TUI) string {
 
 	if t.messages.Thread != nil {
 		threadName = t.messages.Thread.Name
+		if t.messages.Thread.DefaultAgent != "" {
+			threadName += " @" + t.messages.Thread.DefaultAgent
+		}
+		// instrucciones permanentes: persona o `/system` //
+		if t.messages.Thread.Persona != "" {
+			threadName += " ◆" + t.messages.Thread.Persona
+		} else if t.messages.Thread.Instructions != "" {
+			threadName += " ◆system"
+		}
 	}
 
-	return t.styles.header.
+	header := t.styles.header.
 		Margin(0, 0, 0, 4).
 		Render("AATUI") + t.styles.help.
 		Margin(0, 0, 0, 2).
@@ -28,4 +39,78 @@ func HeaderViewTui(t *TUI) string {
 			len(t.messages.Threads),
 			threadName,
 		))
+
+	// Modelo y parámetros ajustados con /model y /set //
+	if settings := SettingsText(t); settings != "" {
+		header += t.styles.help.
+			Margin(0, 0, 0, 1).
+			Render("⚙ " + settings)
+	}
+
+	// Archivos preparados con /attach para el próximo mensaje //
+	if pending := len(t.messages.Pending); pending > 0 {
+		header += t.styles.help.
+			Margin(0, 0, 0, 1).
+			Render(fmt.Sprintf("📎 %d", pending))
+	}
+
+	// Tokens consumidos en el hilo actual //
+	if t.messages.Tokens > 0 {
+		header += t.styles.help.
+			Margin(0, 0, 0, 1).
+			Render(fmt.Sprintf("Σ %d tok", t.messages.Tokens))
+	}
+
+	// Respuestas llegadas a otros hilos //
+	if unread := t.messages.TotalUnread(); unread > 0 {
+		header += t.styles.alert.
+			Margin(0, 0, 0, 1).
+			Render(fmt.Sprintf("✉ %d", unread))
+	}
+
+	return header
+}
+
+// AttachmentChips resume los adjuntos de un mensaje para su cabecera
+func AttachmentChips(attachments []modelpkg.AttachmentModel) string {
+	const shown = 3
+	var chips []string
+	for idx, attachment := range attachments {
+		if idx == shown {
+			chips = append(chips, fmt.Sprintf("+%d", len(attachments)-shown))
+			break
+		}
+		chips = append(chips, "📎 "+attachment.Path)
+	}
+	return strings.Join(chips, " ")
+}
+
+// SettingsText resume los ajustes vigentes en el hilo actual
+func SettingsText(t *TUI) string {
+	if t.settings == nil {
+		return ""
+	}
+	threadId := ""
+	if t.messages.Thread != nil {
+		threadId = t.messages.Thread.Id
+	}
+	settings := t.settings.Resolve(threadId)
+
+	var parts []string
+	if settings.Model != "" {
+		parts = append(parts, settings.Model)
+	}
+	if settings.Temperature != nil {
+		parts = append(parts, fmt.Sprintf("t=%g", *settings.Temperature))
+	}
+	if settings.TopP != nil {
+		parts = append(parts, fmt.Sprintf("p=%g", *settings.TopP))
+	}
+	if settings.MaxOutputTokens != nil {
+		parts = append(parts, fmt.Sprintf("max=%d", *settings.MaxOutputTokens))
+	}
+	if settings.ReasoningEffort != "" {
+		parts = append(parts, "effort="+settings.ReasoningEffort)
+	}
+	return strings.Join(parts, " ")
 }
@@ -0,0 +1,125 @@
+package tuipkg
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+
+	modelpkg "main/src/model"
+)
+
+type AgentEventModel = modelpkg.AgentEventModel
+
+// tiempo que un aviso de caída permanece en el panel
+const toastDuration = 6 * time.Second
+
+type toast struct {
+	text  string
+	until time.Time
+}
+
+// toastExpiredMsg llega cuando algún aviso debe desaparecer
+type toastExpiredMsg struct{}
+
+// AgentEvent actualiza el panel de agentes con un evento del Manager;
+// las caídas abren un aviso temporal
+func (t *TUI) AgentEvent(event AgentEventModel) tea.Cmd {
+	if event.State == modelpkg.AgentRemoved {
+		delete(t.agents, event.Name)
+	} else {
+		t.agents[event.Name] = event
+	}
+	if !event.Crashed() {
+		return nil
+	}
+
+	text := "✖ " + event.Name + ": " + event.Err
+	switch event.State {
+	case modelpkg.AgentRestarting:
+		text += fmt.Sprintf(" · reinicio en %s", event.Backoff)
+	case modelpkg.AgentFailed:
+		text += " · demasiados reinicios, detenido"
+	case modelpkg.AgentStopping:
+		text += " · sigue vivo tras el plazo de parada"
+	}
+	t.toasts = append(t.toasts, toast{text: text, until: time.Now().Add(toastDuration)})
+	return tea.Tick(toastDuration, func(time.Time) tea.Msg {
+		return toastExpiredMsg{}
+	})
+}
+
+// expireToasts quita los avisos caducados
+func (t *TUI) expireToasts() {
+	now := time.Now()
+	kept := t.toasts[:0]
+	for _, item := range t.toasts {
+		if now.Before(item.until) {
+			kept = append(kept, item)
+		}
+	}
+	t.toasts = kept
+}
+
+// top, right, bottom, left //
+
+func SidebarViewTui(t *TUI, width int) string {
+	wrap := lipgloss.NewStyle().Width(max(width-2, 10))
+
+	var sb strings.Builder
+	sb.WriteString(t.styles.header.Render("Agentes") + "\n\n")
+	if len(t.agents) == 0 {
+		sb.WriteString(t.styles.help.Render("sin agentes") + "\n")
+	}
+
+	names := make([]string, 0, len(t.agents))
+	for name := range t.agents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		event := t.agents[name]
+
+		var icon string
+		style := t.styles.help
+		switch event.State {
+		case modelpkg.AgentRunning:
+			icon, style = "●", t.styles.agentUp
+		case modelpkg.AgentRestarting:
+			icon, style = "↻", t.styles.alert
+		case modelpkg.AgentFailed:
+			icon, style = "✖", t.styles.agentDown
+		case modelpkg.AgentStopping:
+			icon, style = "…", t.styles.alert
+		case modelpkg.AgentStopped:
+			icon = "■"
+			if event.Crashed() {
+				style = t.styles.agentDown
+			}
+		default:
+			icon = "○"
+		}
+
+		line := icon + " " + name + " · " + event.State
+		if event.Panicked {
+			line += " · panicked"
+		}
+		if event.Restarts > 0 {
+			line += fmt.Sprintf(" ↻%d", event.Restarts)
+		}
+		sb.WriteString(style.Render(wrap.Render(line)) + "\n")
+	}
+
+	// Avisos de caídas recientes //
+	if len(t.toasts) > 0 {
+		sb.WriteString("\n")
+		for _, item := range t.toasts {
+			sb.WriteString(t.styles.agentDown.Render(wrap.Render(item.text)) + "\n")
+		}
+	}
+
+	return sb.String()
+}
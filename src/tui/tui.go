@@ -2,6 +2,7 @@ package tuipkg
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"strings"
 
@@ -18,6 +19,8 @@ import (
 	eventpkg "main/src/event"
 	messagepkg "main/src/message"
 	modelpkg "main/src/model"
+	routerpkg "main/src/router"
+	settingspkg "main/src/settings"
 	// toolspkg "main/src/tools"
 )
 
@@ -30,6 +33,12 @@ type Event = eventpkg.Event
 
 type Command = commandpkg.Command
 
+type Router = routerpkg.Router
+
+type Settings = settingspkg.Settings
+
+type ApprovalModel = modelpkg.ApprovalModel
+
 const (
 	LEFT_WIDTH_PERCENTAGE = 0.6 // 60% del ancho
 	inputHeight           = 3   // borde + entrada + borde
@@ -48,6 +57,8 @@ type TUI struct {
 	messages    *MessageList
 	bus         *OptimizedBus
 	command     *Command
+	router      *Router
+	settings    *Settings
 	mdEnabled   bool
 	mdRendererA *glamour.TermRenderer // with Margin
 	mdRendererB *glamour.TermRenderer // with out Margin
@@ -57,16 +68,23 @@ type TUI struct {
 	showAlert   bool
 	textAlert   string
 	suggestion  *SuggestionsType
+	approvals   []ApprovalModel            // herramientas esperando y/n; se responde la primera
+	agents      map[string]AgentEventModel // último estado de cada agente (EvtAgent)
+	toasts      []toast                    // avisos de caídas recientes
 	styles      struct {
 		header         lipgloss.Style
 		labelSystem    lipgloss.Style
 		labelHuman     lipgloss.Style
 		labelAssistant lipgloss.Style
+		labelTool      lipgloss.Style
+		labelSummary   lipgloss.Style
 		body           lipgloss.Style
 		dots           lipgloss.Style
 		help           lipgloss.Style
 		inputBox       lipgloss.Style
 		alert          lipgloss.Style
+		agentUp        lipgloss.Style
+		agentDown      lipgloss.Style
 	}
 }
 
@@ -75,6 +93,8 @@ func NewTUI(
 	bus *OptimizedBus,
 	messages *MessageList,
 	command *Command,
+	router *Router,
+	settings *Settings,
 	logger *slog.Logger,
 ) *TUI {
 	// toolspkg.LoadSuggestions(conf)
@@ -113,9 +133,12 @@ func NewTUI(
 		bus:        bus,
 		messages:   messages,
 		command:    command,
+		router:     router,
+		settings:   settings,
 		logger:     logger,
 		showAlert:  false,
 		suggestion: NewSuggestions(conf),
+		agents:     map[string]AgentEventModel{},
 	}
 
 	s := &t.styles
@@ -124,6 +147,8 @@ func NewTUI(
 	s.labelSystem = lipgloss.NewStyle().PaddingLeft(1).Foreground(lipgloss.Color("#E07093"))
 	s.labelHuman = lipgloss.NewStyle().PaddingLeft(1).Foreground(lipgloss.Color("#38ACEC"))
 	s.labelAssistant = lipgloss.NewStyle().PaddingLeft(1).Foreground(lipgloss.Color("#29BEB0"))
+	s.labelTool = lipgloss.NewStyle().PaddingLeft(1).Foreground(lipgloss.Color("#B8A000"))
+	s.labelSummary = lipgloss.NewStyle().PaddingLeft(1).Italic(true).Foreground(lipgloss.Color("#A07DE0"))
 	s.body = lipgloss.NewStyle().PaddingLeft(1)
 	s.dots = lipgloss.NewStyle().Foreground(lipgloss.Color("#444"))
 	s.help = lipgloss.NewStyle().Foreground(lipgloss.Color("#666"))
@@ -133,6 +158,8 @@ func NewTUI(
 		Margin(0, 2, 0, 2).
 		Padding(0, 1)
 	s.alert = lipgloss.NewStyle().Foreground(lipgloss.Color("#FFC400"))
+	s.agentUp = lipgloss.NewStyle().Foreground(lipgloss.Color("#29BEB0"))
+	s.agentDown = lipgloss.NewStyle().Foreground(lipgloss.Color("#E0475B"))
 
 	t.program = tea.NewProgram(t, tea.WithAltScreen())
 
@@ -161,10 +188,41 @@ func (t *TUI) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		t.input.SetSuggestions(msg)
 
 	case tea.KeyMsg:
+		// Con una aprobación pendiente, "y" o "n" solos en la entrada + Enter la responden //
+		if len(t.approvals) > 0 && msg.Type == tea.KeyEnter {
+			switch reply := strings.ToLower(strings.TrimSpace(t.input.Value())); reply {
+			case "y", "n":
+				answer := t.approvals[0]
+				answer.Answered = true
+				answer.Approved = reply == "y"
+				t.approvals = t.approvals[1:]
+				t.input.Reset()
+				t.bus.Publish(eventpkg.EvtApproval, answer)
+				return t, nil
+			}
+		}
+
+		// Alt+, / Alt+. cambian a la rama hermana anterior / siguiente //
+		switch msg.String() {
+		case "alt+,", "alt+.":
+			delta := 1
+			if msg.String() == "alt+," {
+				delta = -1
+			}
+			if t.messages.Flip(delta) {
+				t.RenderBody()
+			}
+			return t, nil
+		}
+
 		switch msg.Type {
 		case tea.KeyCtrlC, tea.KeyEsc:
 			cmds = append(cmds, tea.Quit)
 
+		case tea.KeyCtrlX:
+			// Cancela la petición en curso de todos los agentes //
+			t.bus.Publish(eventpkg.EvtCancel, "")
+
 		case tea.KeyEnter:
 			rawText := t.input.Value()
 			text := strings.TrimSpace(rawText)
@@ -174,6 +232,19 @@ func (t *TUI) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					Source: modelpkg.ScHuman,
 					Text:   text,
 				}
+				if isCmd, _ := t.command.IsCommand(text); !isCmd {
+					msg = t.messages.Stamp(msg)
+					msg = t.router.Route(msg, t.messages.Thread)
+					var warnings []string
+					msg, warnings = t.messages.Attach(msg)
+					if len(warnings) > 0 {
+						t.bus.Publish(eventpkg.EvtMessage, MessageModel{
+							Type:   modelpkg.TySystem,
+							Source: modelpkg.ScSystem,
+							Text:   "No se adjuntaron:\n- " + strings.Join(warnings, "\n- "),
+						})
+					}
+				}
 				t.bus.Publish(eventpkg.EvtMessage, msg)
 				t.input.Reset()
 				t.input.SetValue("")
@@ -228,7 +299,10 @@ func (t *TUI) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				t.textAlert = t.styles.alert.
 					Align(lipgloss.Right).
 					Render("loading")
-				t.showAlert = !t.showAlert
+				t.showAlert = true
+
+			case "loaded":
+				t.showAlert = false
 
 			default:
 				panic("Command Unknown")
@@ -258,9 +332,40 @@ func (t *TUI) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					t.messages.AddMessage(msgData)
 				}
 			}
+
+		case eventpkg.EvtPartial:
+			if msgData, ok := evt.Data.(MessageModel); ok {
+				t.messages.UpdatePartial(msgData)
+			}
+
+		case eventpkg.EvtApproval:
+			if approval, ok := evt.Data.(ApprovalModel); ok {
+				if !approval.Answered {
+					t.approvals = append(t.approvals, approval)
+					break
+				}
+				// respondida o retirada por el agente (cancelación) //
+				for idx, item := range t.approvals {
+					if item.Id == approval.Id {
+						t.approvals = append(t.approvals[:idx], t.approvals[idx+1:]...)
+						break
+					}
+				}
+			}
+
+		case eventpkg.EvtAgent:
+			if event, ok := evt.Data.(AgentEventModel); ok {
+				if cmd := t.AgentEvent(event); cmd != nil {
+					cmds = append(cmds, cmd)
+				}
+			}
 		}
 		t.RenderBody()
 
+	case toastExpiredMsg:
+		t.expireToasts()
+		return t, nil
+
 	default:
 		var cmd tea.Cmd
 		t.spinner, cmd = t.spinner.Update(msg)
@@ -283,9 +388,8 @@ func (t *TUI) View() string {
 		int(float64(t.width)*(1-LEFT_WIDTH_PERCENTAGE)),
 		t.viewport.Height+1,
 	)
-	nvp.SetContent("")
+	nvp.SetContent(SidebarViewTui(t, nvp.Width))
 	nvp.Style = lipgloss.NewStyle().
-		Background(lipgloss.Color("#8A7DFC")).
 		BorderStyle(lipgloss.RoundedBorder()).
 		BorderForeground(lipgloss.Color("#8A7DFC")).
 		BorderTop(true).
@@ -370,6 +474,7 @@ func (t *TUI) MDRenderer(width int) {
 func (t *TUI) PrePrintMessages() string {
 	var sb strings.Builder
 
+	human := 0
 	for index, message := range t.messages.Messages {
 		if index > 0 {
 			sb.WriteString(t.DottedLine(t.width) + "\n")
@@ -383,9 +488,34 @@ func (t *TUI) PrePrintMessages() string {
 			label = t.styles.labelSystem
 		case modelpkg.ScHuman:
 			label = t.styles.labelHuman
+			if message.Type == modelpkg.TyText {
+				human++
+				header += fmt.Sprintf(" #%d", human)
+			}
+			if len(message.To) > 0 {
+				header += " → " + strings.Join(message.To, ", ")
+			}
 		case modelpkg.ScAssistant:
 			label = t.styles.labelAssistant
 			header += " [" + message.WrittenBy + "]"
+			if message.Cached {
+				header += " · cached"
+			}
+		}
+		switch message.Type {
+		case modelpkg.TyTool:
+			label = t.styles.labelTool
+			header = message.Type.String() + " [" + message.WrittenBy + "]"
+		case modelpkg.TySummary:
+			// los mensajes anteriores siguen guardados; el agente solo ve el resumen //
+			label = t.styles.labelSummary
+			header = "⧉ " + message.Type.String() + " [" + message.WrittenBy + "] · contexto compactado"
+		}
+		if len(message.Attachments) > 0 {
+			header += " " + AttachmentChips(message.Attachments)
+		}
+		if pos, total := t.messages.Siblings(message); total > 1 && pos > 0 {
+			header += fmt.Sprintf(" ‹%d/%d›", pos, total)
 		}
 		if !message.CreatedAt.IsZero() {
 			header += " - " + message.CreatedAt.Format("15:04:05")